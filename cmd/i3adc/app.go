@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/seeruk/i3adc/config"
+	"github.com/seeruk/i3adc/control"
+	"github.com/seeruk/i3adc/i3adc"
+	"github.com/seeruk/i3adc/state"
+	"github.com/seeruk/i3adc/xrandr"
+)
+
+// errDaemonNotRunning is returned by commands that only make sense when the daemon is running.
+var errDaemonNotRunning = errors.New("the i3adc daemon is not running")
+
+// layoutManager is the set of layout operations that commands use. It's implemented both by the
+// layout manager itself, and by the control socket client, which asks the daemon's layout manager.
+type layoutManager interface {
+	Status() (xrandr.Status, error)
+	Layouts() ([]xrandr.LayoutInfo, error)
+	Layout(ref string) (xrandr.LayoutInfo, error)
+	Apply(ref string) error
+	DeleteLayout(ref string) (string, error)
+	Save(profile string) (string, error)
+	Reload() error
+	Revisions(ref string) (string, []xrandr.Revision, error)
+	DiffRevisions(ref string, from, to int) ([]string, error)
+	Revert(ref string, revision int) (bool, error)
+	Profiles() ([]xrandr.Profile, string, error)
+	SaveProfile(name, ref string) (xrandr.Profile, error)
+	ActivateProfile(name string) error
+	DeleteProfile(name string) error
+	Commands(ref string) (xrandr.Commands, error)
+	SetCommands(ref string, commands []string) (xrandr.Commands, error)
+}
+
+// app provides commands with the things they need, creating them only when they're asked for.
+type app struct {
+	out *printer
+
+	client   *control.Client
+	config   *config.Config
+	resolver *i3adc.Resolver
+}
+
+// newApp returns a new app instance, that will write command output using the given printer.
+func newApp(out *printer) *app {
+	return &app{
+		out: out,
+	}
+}
+
+// manager returns the layout manager that commands should use. If the daemon is running, then the
+// command is routed through it's control socket. Otherwise, the database can be opened directly,
+// because the daemon isn't holding a lock on it.
+func (a *app) manager() (layoutManager, error) {
+	client, err := a.daemonClient()
+	if err == nil {
+		return client, nil
+	}
+
+	if a.resolver == nil {
+		// The daemon can be running with it's control socket unreachable (e.g. if it's control
+		// thread gave up), in which case it still holds the database, and opening it would fail.
+		err = checkDaemonStopped()
+		if err != nil {
+			return nil, err
+		}
+
+		cfg, err := a.loadConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		a.resolver = i3adc.NewCommandResolver(cfg)
+	}
+
+	return a.resolver.ResolveXrandrManager(), nil
+}
+
+// loadConfig loads and validates the user's configuration file, the first time it's called.
+func (a *app) loadConfig() (config.Config, error) {
+	if a.config != nil {
+		return *a.config, nil
+	}
+
+	path, err := config.Path()
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	a.config = &cfg
+
+	return cfg, nil
+}
+
+// reloadConfig loads and validates the user's configuration file again. If it's invalid, the
+// configuration that was loaded before is kept.
+func (a *app) reloadConfig() (config.Config, error) {
+	previous := a.config
+	a.config = nil
+
+	cfg, err := a.loadConfig()
+	if err != nil {
+		a.config = previous
+	}
+
+	return cfg, err
+}
+
+// daemonClient returns a client connected to the running daemon's control socket.
+func (a *app) daemonClient() (*control.Client, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
+
+	path, err := control.SocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := control.Dial(path)
+	if err != nil {
+		return nil, errDaemonNotRunning
+	}
+
+	a.client = client
+
+	return client, nil
+}
+
+// checkDaemonStopped returns an error if a daemon holds the instance lock, which means it's
+// running, even though it's control socket couldn't be reached.
+func checkDaemonStopped() error {
+	path, err := state.LockPath()
+	if err != nil {
+		return err
+	}
+
+	lock, err := state.AcquireLock(path)
+	if lockedErr, ok := err.(*state.LockedError); ok {
+		if lockedErr.PID == 0 {
+			return errors.New("the i3adc daemon is running, but it's control socket isn't reachable")
+		}
+
+		return fmt.Errorf("the i3adc daemon is running as PID %d, but it's control socket isn't "+
+			"reachable", lockedErr.PID)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return lock.Release()
+}
+
+// close cleans up anything that was opened for commands. If the command changed layouts itself,
+// this waits for any hooks it started.
+func (a *app) close() {
+	if a.client != nil {
+		a.client.Close()
+	}
+
+	if a.resolver != nil {
+		a.resolver.ResolveHookRunner().Wait()
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// i3CommandsUsage describes how the commands command is used.
+const i3CommandsUsage = `commands [show] [layout|profile]
+commands set <layout|profile> <command>...
+commands clear <layout|profile>`
+
+// runI3Commands runs the commands command, which manages the i3 commands that are run after a
+// layout or profile is applied. If no layout is given to show, the latest layout is used.
+func runI3Commands(app *app, args []string) error {
+	action := "show"
+	if len(args) > 0 && (args[0] == "show" || args[0] == "set" || args[0] == "clear") {
+		action, args = args[0], args[1:]
+	}
+
+	manager, err := app.manager()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "show":
+		if len(args) > 1 {
+			return errUsage
+		}
+
+		commands, err := manager.Commands(optionalArg(args, 0))
+		if err != nil {
+			return err
+		}
+
+		return app.out.print(commands, func(w io.Writer) error {
+			for _, command := range commands.Commands {
+				fmt.Fprintln(w, command)
+			}
+
+			return nil
+		})
+	case "set", "clear":
+		if (action == "set" && len(args) < 2) || (action == "clear" && len(args) != 1) {
+			return errUsage
+		}
+
+		commands, err := manager.SetCommands(args[0], args[1:])
+		if err != nil {
+			return err
+		}
+
+		fields := map[string]interface{}{"hash": commands.Hash, "profile": commands.Profile, "commands": commands.Commands}
+
+		target := "layout " + shortHash(commands.Hash)
+		if commands.Profile != "" {
+			target = fmt.Sprintf("profile %q", commands.Profile)
+		}
+
+		return app.out.message(fields, "set %d i3 commands for %s", len(commands.Commands), target)
+	}
+
+	return errUsage
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/seeruk/i3adc/daemon"
+	"github.com/seeruk/i3adc/event"
+	"github.com/seeruk/i3adc/i3adc"
+	"github.com/seeruk/i3adc/logging"
+	"github.com/seeruk/i3adc/state"
+	"github.com/seeruk/i3adc/systemd"
+)
+
+// Usage information for the daemon command.
+const daemonUsage = "daemon [--replace]"
+
+// replaceTimeout is how long to wait for a running daemon to exit when replacing it.
+const replaceTimeout = 10 * time.Second
+
+// runDaemon starts i3adc's background threads, and waits for them to finish, or for a signal
+// telling it to stop.
+func runDaemon(app *app, args []string) error {
+	var replace bool
+	for _, arg := range args {
+		if arg != "--replace" {
+			return errUsage
+		}
+
+		replace = true
+	}
+
+	cfg, err := app.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// The lock has to be held before the database is opened, as the database can only be opened
+	// by one process at a time, and would otherwise just time out.
+	lock, err := acquireInstanceLock(app, replace)
+	if err != nil {
+		return err
+	}
+
+	defer lock.Release()
+
+	resolver := i3adc.NewResolver(cfg)
+
+	logger := resolver.ResolveLogger()
+	logger = logger.With("module", "main")
+	logger.Info("i3adc starting...")
+
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1)
+
+	supervisor := daemon.NewSupervisor("daemon", resolver.ResolveLogger())
+
+	// If i3adc was started by systemd, it's told when i3adc is ready, and kept up to date with it's
+	// status. Otherwise, the notifier is nil, and does nothing.
+	notifier := resolver.ResolveSystemdNotifier()
+
+	// The threads that i3adc can't do it's job without.
+	critical := []string{"xrandr"}
+
+	// The i3 thread may be disabled, in which case the layout is only checked at startup, and when
+	// asked to by a command. It only stops on it's own when i3 exits, taking the session with it,
+	// so i3adc stops too.
+	if cfg.Events.I3.Enabled {
+		critical = append(critical, "i3")
+
+		supervisor.Add(daemon.ThreadSpec{
+			Name:     "i3",
+			New:      func() daemon.Thread { return resolver.ResolveI3Thread() },
+			Policy:   daemon.RestartOnFailure,
+			Critical: true,
+		})
+	}
+
+	supervisor.Add(daemon.ThreadSpec{
+		Name: "xrandr",
+		New: func() daemon.Thread {
+			thread := resolver.ResolveXrandrThread()
+			if notifier != nil {
+				thread.OnHandled(notifyStatus(notifier, resolver.ResolveXrandrManager(), logger))
+			}
+
+			return thread
+		},
+		Policy:      daemon.RestartOnFailure,
+		MaxRestarts: 5,
+		Critical:    true,
+	})
+
+	// Not every system has logind, and without it, i3adc just won't notice the machine resuming.
+	if cfg.Events.Logind.Enabled {
+		supervisor.Add(daemon.ThreadSpec{
+			Name:        "logind",
+			New:         func() daemon.Thread { return resolver.ResolveLogindThread() },
+			Policy:      daemon.RestartOnFailure,
+			MaxRestarts: 3,
+		})
+	}
+
+	// The kernel's device events are an extra source of hotplug events, for when i3 is slow to
+	// notice, or doesn't at all.
+	if cfg.Events.Udev.Enabled {
+		supervisor.Add(daemon.ThreadSpec{
+			Name:        "udev",
+			New:         func() daemon.Thread { return resolver.ResolveUdevThread() },
+			Policy:      daemon.RestartOnFailure,
+			MaxRestarts: 3,
+		})
+	}
+
+	// Some drivers, and remote X setups, never report changes to the outputs, so they're polled.
+	if cfg.Events.Poll.Enabled {
+		supervisor.Add(daemon.ThreadSpec{
+			Name:        "poll",
+			New:         func() daemon.Thread { return resolver.ResolvePollThread() },
+			Policy:      daemon.RestartOnFailure,
+			MaxRestarts: 3,
+		})
+	}
+
+	// The control socket is a convenience, so i3adc carries on managing displays without it.
+	supervisor.Add(daemon.ThreadSpec{
+		Name:        "control",
+		New:         func() daemon.Thread { return resolver.ResolveControlServer() },
+		Policy:      daemon.RestartOnFailure,
+		MaxRestarts: 3,
+	})
+
+	if notifier != nil && systemd.WatchdogInterval() > 0 {
+		supervisor.Add(daemon.ThreadSpec{
+			Name: "watchdog",
+			New: func() daemon.Thread {
+				return resolver.ResolveSystemdWatchdog(isHealthy(supervisor, critical...))
+			},
+			Policy: daemon.RestartAlways,
+		})
+	}
+
+	supervisor.OnChange = newReadiness(notifier, logger, critical...).update
+
+	done := make(chan error, 1)
+	go func() {
+		done <- supervisor.Run(ctx)
+	}()
+
+wait:
+	for {
+		select {
+		case sig := <-signals:
+			switch sig {
+			case syscall.SIGHUP:
+				reloadConfig(app, resolver, logger)
+				continue
+			case syscall.SIGUSR1:
+				logger.Info("re-evaluating outputs")
+
+				resolver.ResolveEventBus().Publish(event.New(event.SourceSignal, event.ReasonUserRequest))
+
+				continue
+			case syscall.SIGINT:
+				fmt.Println() // Skip the ^C
+			}
+
+			logger.Infow("stopping background threads", "signal", sig)
+			break wait
+		case <-resolver.ResolveShutdownChannel():
+			logger.Info("asked to exit, stopping background threads")
+			break wait
+		case err = <-done:
+			// The group stopped itself, so there's nothing left to wait for.
+			notifier.Stopping()
+			return finishDaemon(logger, err)
+		}
+	}
+
+	notifier.Stopping()
+	cfn()
+
+	go func() {
+		time.AfterFunc(5*time.Second, func() {
+			logger.Error("took too long stopping, exiting")
+			os.Exit(1)
+		})
+	}()
+
+	// Wait for our background threads to clean up.
+	return finishDaemon(logger, <-done)
+}
+
+// reloadConfig reloads the user's configuration file, and passes the parts that can be changed
+// while i3adc is running on to the things that use them. If the file is invalid, the configuration
+// that was already loaded is kept.
+func reloadConfig(app *app, resolver *i3adc.Resolver, logger logging.Logger) {
+	old, _ := app.loadConfig()
+
+	cfg, err := app.reloadConfig()
+	if err != nil {
+		logger.Errorw("failed to reload configuration, keeping the current configuration", "error", err)
+		return
+	}
+
+	resolver.ResolveXrandrManager().SetConfig(cfg)
+	resolver.ResolveHookRunner().SetConfig(cfg.Hooks)
+
+	if !reflect.DeepEqual(cfg.Logging, old.Logging) || cfg.State != old.State || cfg.Events != old.Events {
+		logger.Warn("configuration reloaded, but changes to logging, state, and events need a restart")
+		return
+	}
+
+	logger.Info("configuration reloaded")
+}
+
+// finishDaemon logs that i3adc is exiting, and returns the given error from the background
+// threads, if there was one.
+func finishDaemon(logger logging.Logger, err error) error {
+	if err != nil {
+		logger.Errorw("background threads failed", "error", err)
+		return err
+	}
+
+	logger.Info("i3adc exiting...")
+
+	return nil
+}
+
+// acquireInstanceLock takes the lock that makes sure only one daemon runs at once. If another
+// daemon holds it, and replace is true, that daemon is asked to exit, and the lock is taken once
+// it has.
+func acquireInstanceLock(app *app, replace bool) (*state.Lock, error) {
+	path, err := state.LockPath()
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := state.AcquireLock(path)
+
+	lockedErr, ok := err.(*state.LockedError)
+	if !ok {
+		return lock, err
+	}
+
+	if !replace {
+		return nil, fmt.Errorf("%s (use --replace to take over)", describeRunning(lockedErr.PID))
+	}
+
+	err = stopRunningDaemon(app, lockedErr.PID)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(replaceTimeout)
+	for {
+		lock, err = state.AcquireLock(path)
+		if _, ok := err.(*state.LockedError); !ok || time.Now().After(deadline) {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if _, ok := err.(*state.LockedError); ok {
+		return nil, fmt.Errorf("%s, and didn't exit in time", describeRunning(lockedErr.PID))
+	}
+
+	return lock, err
+}
+
+// stopRunningDaemon asks the running daemon to exit, through it's control socket if possible, and
+// otherwise by sending SIGTERM to the process with the given PID.
+func stopRunningDaemon(app *app, pid int) error {
+	client, err := app.daemonClient()
+	if err == nil {
+		return client.Shutdown()
+	}
+
+	if pid == 0 {
+		return fmt.Errorf("%s, but can't be reached to stop it", describeRunning(pid))
+	}
+
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+// describeRunning describes the running daemon with the given PID, which may be 0 if it's unknown.
+func describeRunning(pid int) string {
+	if pid == 0 {
+		return "i3adc is already running"
+	}
+
+	return fmt.Sprintf("i3adc is already running as PID %d", pid)
+}
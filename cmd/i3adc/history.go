@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// historyUsage describes how the history command is used.
+const historyUsage = `history [list] [layout]
+history diff <from> <to> [layout]
+history revert <revision> [layout]`
+
+// runHistory runs the history command, which can list, diff, and revert to revisions of a layout.
+// If no layout is given, the latest layout is used.
+func runHistory(app *app, args []string) error {
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	manager, err := app.manager()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		if len(args) > 1 {
+			return errUsage
+		}
+
+		hash, revisions, err := manager.Revisions(optionalArg(args, 0))
+		if err != nil {
+			return err
+		}
+
+		result := map[string]interface{}{
+			"hash":      hash,
+			"revisions": revisions,
+		}
+
+		return app.out.print(result, func(w io.Writer) error {
+			fmt.Fprintf(w, "layout %s\n\n", hash)
+
+			writer := newTabWriter(w)
+			fmt.Fprintln(writer, "REVISION\tSAVED\tOUTPUTS")
+
+			for i := len(revisions) - 1; i >= 0; i-- {
+				revision := revisions[i]
+
+				var enabled int
+				for _, output := range revision.Outputs {
+					if output.IsEnabled {
+						enabled++
+					}
+				}
+
+				fmt.Fprintf(writer, "%d\t%s\t%d enabled\n", revision.ID, formatTime(revision.Time), enabled)
+			}
+
+			return writer.Flush()
+		})
+	case "diff":
+		if len(args) < 2 || len(args) > 3 {
+			return errUsage
+		}
+
+		from, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid revision %q", args[0])
+		}
+
+		to, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid revision %q", args[1])
+		}
+
+		diff, err := manager.DiffRevisions(optionalArg(args, 2), from, to)
+		if err != nil {
+			return err
+		}
+
+		result := map[string]interface{}{
+			"from":    from,
+			"to":      to,
+			"changes": diff,
+		}
+
+		return app.out.print(result, func(w io.Writer) error {
+			if len(diff) == 0 {
+				fmt.Fprintln(w, "revisions are identical")
+			}
+
+			for _, line := range diff {
+				fmt.Fprintln(w, line)
+			}
+
+			return nil
+		})
+	case "revert":
+		if len(args) < 1 || len(args) > 2 {
+			return errUsage
+		}
+
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid revision %q", args[0])
+		}
+
+		applied, err := manager.Revert(optionalArg(args, 1), id)
+		if err != nil {
+			return err
+		}
+
+		fields := map[string]interface{}{
+			"revision": id,
+			"applied":  applied,
+		}
+
+		if !applied {
+			return app.out.message(fields, "reverted to revision %d, it will be applied when it's outputs are connected", id)
+		}
+
+		return app.out.message(fields, "reverted to revision %d, and applied it", id)
+	}
+
+	return errUsage
+}
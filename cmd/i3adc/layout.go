@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Usage information for the layout commands.
+const (
+	statusUsage = "status"
+	listUsage   = "list"
+	showUsage   = "show [layout]"
+	applyUsage  = "apply <layout>"
+	deleteUsage = "delete <layout>"
+	saveUsage   = "save [profile]"
+	reloadUsage = "reload"
+	pauseUsage  = "pause"
+	resumeUsage = "resume"
+)
+
+// runStatus runs the status command, which shows the connected outputs, and their layout.
+func runStatus(app *app, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	manager, err := app.manager()
+	if err != nil {
+		return err
+	}
+
+	status, err := manager.Status()
+	if err != nil {
+		return err
+	}
+
+	return app.out.print(status, func(w io.Writer) error {
+		saved := "saved"
+		if !status.IsSaved {
+			saved = "not saved"
+		}
+
+		fmt.Fprintf(w, "layout:   %s (%s)\n", status.Hash, saved)
+		fmt.Fprintf(w, "latest:   %s\n", status.LatestHash)
+
+		if status.ActiveProfile != "" {
+			fmt.Fprintf(w, "profile:  %s\n", status.ActiveProfile)
+		}
+
+		if status.IsPaused {
+			fmt.Fprintln(w, "paused:   true")
+		}
+
+		fmt.Fprintln(w, "outputs:")
+
+		return writeOutputs(w, status.Outputs)
+	})
+}
+
+// runList runs the list command, which lists every saved layout.
+func runList(app *app, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	manager, err := app.manager()
+	if err != nil {
+		return err
+	}
+
+	layouts, err := manager.Layouts()
+	if err != nil {
+		return err
+	}
+
+	return app.out.print(layouts, func(w io.Writer) error {
+		writer := newTabWriter(w)
+		fmt.Fprintln(writer, "\tLAYOUT\tOUTPUTS\tPROFILES\tUPDATED")
+
+		for _, layout := range layouts {
+			marker := ""
+			if layout.IsLatest {
+				marker = "*"
+			}
+
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n",
+				marker,
+				shortHash(layout.Hash),
+				joinOutputNames(layout.Outputs),
+				strings.Join(layout.Profiles, ","),
+				formatTime(layout.UpdatedAt),
+			)
+		}
+
+		return writer.Flush()
+	})
+}
+
+// runShow runs the show command, which shows a single saved layout in detail.
+func runShow(app *app, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+
+	manager, err := app.manager()
+	if err != nil {
+		return err
+	}
+
+	layout, err := manager.Layout(optionalArg(args, 0))
+	if err != nil {
+		return err
+	}
+
+	return app.out.print(layout, func(w io.Writer) error {
+		fmt.Fprintf(w, "layout:    %s\n", layout.Hash)
+		fmt.Fprintf(w, "latest:    %t\n", layout.IsLatest)
+
+		if len(layout.Profiles) > 0 {
+			fmt.Fprintf(w, "profiles:  %s\n", strings.Join(layout.Profiles, ", "))
+		}
+
+		fmt.Fprintf(w, "revisions: %d\n", layout.Revisions)
+		fmt.Fprintf(w, "updated:   %s\n", formatTime(layout.UpdatedAt))
+		fmt.Fprintln(w, "outputs:")
+
+		err := writeOutputs(w, layout.Outputs)
+		if err != nil || len(layout.Workspaces) == 0 {
+			return err
+		}
+
+		fmt.Fprintln(w, "workspaces:")
+
+		writer := newTabWriter(w)
+		for _, workspace := range layout.Workspaces {
+			fmt.Fprintf(writer, "  %s\t%s\n", workspace.Name, workspace.Output)
+		}
+
+		return writer.Flush()
+	})
+}
+
+// runApply runs the apply command, which applies a saved layout or profile.
+func runApply(app *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	manager, err := app.manager()
+	if err != nil {
+		return err
+	}
+
+	err = manager.Apply(args[0])
+	if err != nil {
+		return err
+	}
+
+	return app.out.message(map[string]interface{}{"layout": args[0]}, "applied %s", args[0])
+}
+
+// runDelete runs the delete command, which deletes a saved layout.
+func runDelete(app *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	manager, err := app.manager()
+	if err != nil {
+		return err
+	}
+
+	hash, err := manager.DeleteLayout(args[0])
+	if err != nil {
+		return err
+	}
+
+	return app.out.message(map[string]interface{}{"hash": hash}, "deleted layout %s", hash)
+}
+
+// runSave runs the save command, which saves the current configuration as the layout for the
+// connected outputs, and optionally as a profile too.
+func runSave(app *app, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+
+	manager, err := app.manager()
+	if err != nil {
+		return err
+	}
+
+	profile := optionalArg(args, 0)
+
+	hash, err := manager.Save(profile)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{"hash": hash, "profile": profile}
+	if profile != "" {
+		return app.out.message(fields, "saved layout %s as profile %q", shortHash(hash), profile)
+	}
+
+	return app.out.message(fields, "saved layout %s", shortHash(hash))
+}
+
+// runReload runs the reload command, which re-applies the saved layout for the connected outputs.
+func runReload(app *app, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	manager, err := app.manager()
+	if err != nil {
+		return err
+	}
+
+	err = manager.Reload()
+	if err != nil {
+		return err
+	}
+
+	return app.out.message(nil, "reloaded")
+}
+
+// runPause runs the pause command, which stops the daemon from reacting to output changes.
+func runPause(app *app, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	client, err := app.daemonClient()
+	if err != nil {
+		return err
+	}
+
+	err = client.Pause()
+	if err != nil {
+		return err
+	}
+
+	return app.out.message(nil, "paused")
+}
+
+// runResume runs the resume command, which lets the daemon react to output changes again.
+func runResume(app *app, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	client, err := app.daemonClient()
+	if err != nil {
+		return err
+	}
+
+	err = client.Resume()
+	if err != nil {
+		return err
+	}
+
+	return app.out.message(nil, "resumed")
+}
@@ -1,61 +1,117 @@
 package main
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/signal"
-	"time"
-
-	"github.com/seeruk/i3adc/daemon"
-	"github.com/seeruk/i3adc/i3"
-	"github.com/seeruk/i3adc/i3adc"
-	"github.com/seeruk/i3adc/xrandr"
+	"strings"
 )
 
+// errUsage is returned by commands when they're used incorrectly, so that their usage is shown.
+var errUsage = errors.New("invalid usage")
+
+// command is a single i3adc command, run as a sub-command of the i3adc binary.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(app *app, args []string) error
+}
+
+// commands contains every available command, in the order they're shown in the usage information.
+var commands = []command{
+	{name: "daemon", usage: daemonUsage, summary: "Run the i3adc daemon (the default)", run: runDaemon},
+	{name: "status", usage: statusUsage, summary: "Show the connected outputs, and their layout", run: runStatus},
+	{name: "list", usage: listUsage, summary: "List saved layouts", run: runList},
+	{name: "show", usage: showUsage, summary: "Show a saved layout in detail", run: runShow},
+	{name: "apply", usage: applyUsage, summary: "Apply a saved layout, or profile", run: runApply},
+	{name: "delete", usage: deleteUsage, summary: "Delete a saved layout, and it's history", run: runDelete},
+	{name: "save", usage: saveUsage, summary: "Save the current configuration, optionally as a profile", run: runSave},
+	{name: "reload", usage: reloadUsage, summary: "Re-apply the saved layout for the connected outputs", run: runReload},
+	{name: "pause", usage: pauseUsage, summary: "Stop the daemon reacting to output changes", run: runPause},
+	{name: "resume", usage: resumeUsage, summary: "Let the daemon react to output changes again", run: runResume},
+	{name: "history", usage: historyUsage, summary: "List, diff, and revert layout revisions", run: runHistory},
+	{name: "profile", usage: profileUsage, summary: "Manage named layouts", run: runProfile},
+	{name: "commands", usage: i3CommandsUsage, summary: "Manage i3 commands run when a layout is applied", run: runI3Commands},
+}
+
 func main() {
-	resolver := i3adc.NewResolver()
+	args, jsonOutput := parseOutputFlags(os.Args[1:])
 
-	logger := resolver.ResolveLogger()
-	logger = logger.With("module", "main")
-	logger.Info("i3adc starting...")
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return
+	}
 
-	ctx, cfn := context.WithCancel(context.Background())
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
 
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt, os.Kill)
+		app := newApp(newPrinter(os.Stdout, jsonOutput))
 
-	backend := resolver.ResolveStateBackend()
+		err := cmd.run(app, args[1:])
+		app.close()
 
-	// TODO(seeruk): Should these be moved to the resolver? (Yes)
-	i3Thread, i3EventCh := i3.NewThread(resolver.ResolveLogger())
-	i3ThreadDone := daemon.NewBackgroundThread(ctx, i3Thread)
+		if err == errUsage {
+			fmt.Fprintf(os.Stderr, "usage:\n  i3adc %s\n", strings.Replace(cmd.usage, "\n", "\n  i3adc ", -1))
+			os.Exit(2)
+		}
 
-	xrandrThread := xrandr.NewThread(backend, resolver.ResolveXrandrClient(), resolver.ResolveLogger(), i3EventCh)
-	xrandrThreadDone := daemon.NewBackgroundThread(ctx, xrandrThread)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "i3adc: %v\n", err)
+			os.Exit(1)
+		}
 
-	select {
-	case sig := <-signals:
-		fmt.Println() // Skip the ^C
-		logger.Infow("stopping background threads", "signal", sig)
-	case res := <-i3ThreadDone:
-		logger.Fatalw("error starting i3 thread", "error", res.Error())
-	case res := <-xrandrThreadDone:
-		logger.Fatalw("error starting output thread", "error", res.Error())
+		return
 	}
 
-	cfn()
+	fmt.Fprintf(os.Stderr, "i3adc: unknown command %q\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
 
-	go func() {
-		time.AfterFunc(5*time.Second, func() {
-			logger.Error("took too long stopping, exiting")
-			os.Exit(1)
-		})
-	}()
+// printUsage prints the list of available commands.
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: i3adc [--json] <command> [arguments] [--] [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
 
-	// Wait for our background threads to clean up.
-	<-i3ThreadDone
-	<-xrandrThreadDone
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Layouts may be referred to by hash, a unique prefix of a hash, or profile name.")
+}
+
+// parseOutputFlags removes the output format flags from the given arguments, wherever they appear
+// before a "--", returning the remaining arguments, and whether or not JSON output was requested.
+// Everything after a "--" is left as it is, so that arguments like profile names and i3 commands
+// can look like flags.
+func parseOutputFlags(args []string) ([]string, bool) {
+	var jsonOutput bool
+
+	remaining := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			remaining = append(remaining, args[i+1:]...)
+			break
+		}
+
+		if arg == "--json" || arg == "-json" {
+			jsonOutput = true
+			continue
+		}
+
+		remaining = append(remaining, arg)
+	}
+
+	// With no command, i3adc runs the daemon, as it always has.
+	if len(remaining) == 0 || remaining[0] == "--replace" {
+		remaining = append([]string{"daemon"}, remaining...)
+	}
 
-	logger.Info("i3adc exiting...")
+	return remaining, jsonOutput
 }
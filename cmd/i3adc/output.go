@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seeruk/i3adc/xrandr"
+)
+
+// printer writes command results, either as human-readable text, or as JSON.
+type printer struct {
+	writer     io.Writer
+	jsonOutput bool
+}
+
+// newPrinter returns a new printer instance, writing to the given writer.
+func newPrinter(writer io.Writer, jsonOutput bool) *printer {
+	return &printer{
+		writer:     writer,
+		jsonOutput: jsonOutput,
+	}
+}
+
+// print writes the given value as JSON if JSON output is enabled, otherwise it calls the given
+// function to write the value in a human-readable format instead.
+func (p *printer) print(val interface{}, human func(w io.Writer) error) error {
+	if p.jsonOutput {
+		encoder := json.NewEncoder(p.writer)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(val)
+	}
+
+	return human(p.writer)
+}
+
+// message prints a simple message. As JSON, the message is written under the "message" key,
+// alongside the given fields.
+func (p *printer) message(fields map[string]interface{}, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+
+	fields["message"] = msg
+
+	return p.print(fields, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, msg)
+		return err
+	})
+}
+
+// newTabWriter returns a tabwriter for writing aligned columns to the given writer.
+func newTabWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+}
+
+// writeOutputs writes a table describing the given outputs.
+func writeOutputs(w io.Writer, outputs []xrandr.Output) error {
+	writer := newTabWriter(w)
+	for _, output := range outputs {
+		fmt.Fprintf(writer, "  %s\t%s\n", output.Name, xrandr.DescribeOutput(output))
+	}
+
+	return writer.Flush()
+}
+
+// joinOutputNames returns the names of the connected outputs in the given layout, as one string.
+func joinOutputNames(outputs []xrandr.Output) string {
+	return strings.Join(xrandr.ConnectedOutputNames(outputs), ",")
+}
+
+// shortHash returns an abbreviated layout hash, for display purposes.
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+
+	return hash
+}
+
+// formatTime formats the given time for display, handling times that were never set.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	return t.Format(time.RFC1123)
+}
+
+// optionalArg returns the argument at the given index, or an empty string if there isn't one.
+func optionalArg(args []string, i int) string {
+	if len(args) > i {
+		return args[i]
+	}
+
+	return ""
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// profileUsage describes how the profile command is used.
+const profileUsage = `profile [list]
+profile save <name> [layout]
+profile activate <name>
+profile delete <name>`
+
+// runProfile runs the profile command, which manages named layouts. If no layout is given when
+// saving a profile, the latest layout is saved.
+func runProfile(app *app, args []string) error {
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	manager, err := app.manager()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		if len(args) > 0 {
+			return errUsage
+		}
+
+		profiles, active, err := manager.Profiles()
+		if err != nil {
+			return err
+		}
+
+		result := map[string]interface{}{
+			"active":   active,
+			"profiles": profiles,
+		}
+
+		return app.out.print(result, func(w io.Writer) error {
+			writer := newTabWriter(w)
+			fmt.Fprintln(writer, "\tNAME\tLAYOUT\tOUTPUTS\tLAST USED")
+
+			for _, profile := range profiles {
+				marker := ""
+				if profile.Name == active {
+					marker = "*"
+				}
+
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n",
+					marker,
+					profile.Name,
+					shortHash(profile.Hash),
+					joinOutputNames(profile.Outputs),
+					formatTime(profile.LastUsedAt),
+				)
+			}
+
+			return writer.Flush()
+		})
+	case "save":
+		if len(args) < 1 || len(args) > 2 {
+			return errUsage
+		}
+
+		profile, err := manager.SaveProfile(args[0], optionalArg(args, 1))
+		if err != nil {
+			return err
+		}
+
+		fields := map[string]interface{}{"profile": profile.Name, "hash": profile.Hash}
+
+		return app.out.message(fields, "saved layout %s as profile %q", shortHash(profile.Hash), profile.Name)
+	case "activate":
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		err := manager.ActivateProfile(args[0])
+		if err != nil {
+			return err
+		}
+
+		return app.out.message(map[string]interface{}{"profile": args[0]}, "activated profile %q", args[0])
+	case "delete":
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		err := manager.DeleteProfile(args[0])
+		if err != nil {
+			return err
+		}
+
+		return app.out.message(map[string]interface{}{"profile": args[0]}, "deleted profile %q", args[0])
+	}
+
+	return errUsage
+}
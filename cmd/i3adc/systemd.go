@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/seeruk/i3adc/daemon"
+	"github.com/seeruk/i3adc/logging"
+	"github.com/seeruk/i3adc/systemd"
+	"github.com/seeruk/i3adc/xrandr"
+)
+
+// readiness tells systemd that the daemon is ready once each of a set of threads is running.
+type readiness struct {
+	mu       sync.Mutex
+	notifier *systemd.Notifier
+	logger   logging.Logger
+	pending  map[string]bool
+}
+
+// newReadiness returns a new readiness instance, waiting for the threads with the given names.
+func newReadiness(notifier *systemd.Notifier, logger logging.Logger, names ...string) *readiness {
+	pending := make(map[string]bool, len(names))
+	for _, name := range names {
+		pending[name] = true
+	}
+
+	return &readiness{
+		notifier: notifier,
+		logger:   logger,
+		pending:  pending,
+	}
+}
+
+// update records the given thread state, and notifies systemd if it was the last thread that was
+// being waited for. It's meant to be used as a supervisor's OnChange function.
+func (r *readiness) update(state daemon.ThreadState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if state.Status != daemon.StatusRunning || !r.pending[state.Name] {
+		return
+	}
+
+	delete(r.pending, state.Name)
+
+	if len(r.pending) > 0 {
+		return
+	}
+
+	err := r.notifier.Ready()
+	if err != nil {
+		r.logger.Errorw("failed to notify systemd of readiness", "error", err)
+	}
+}
+
+// isHealthy returns a function that reports whether each of the threads with the given names is
+// running in the given supervisor.
+func isHealthy(supervisor *daemon.Supervisor, names ...string) func() bool {
+	return func() bool {
+		running := make(map[string]bool)
+		for _, state := range supervisor.States() {
+			running[state.Name] = state.Status == daemon.StatusRunning
+		}
+
+		for _, name := range names {
+			if !running[name] {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// notifyStatus returns a function that sets systemd's status line for i3adc to describe the active
+// layout.
+func notifyStatus(notifier *systemd.Notifier, manager *xrandr.Manager, logger logging.Logger) func() {
+	return func() {
+		status, err := manager.Status()
+		if err != nil {
+			logger.Errorw("failed to get status for systemd", "error", err)
+			return
+		}
+
+		err = notifier.Status(describeStatus(status))
+		if err != nil {
+			logger.Errorw("failed to notify systemd of status", "error", err)
+		}
+	}
+}
+
+// describeStatus returns a single line describing the given status.
+func describeStatus(status xrandr.Status) string {
+	desc := fmt.Sprintf("layout %s", shortHash(status.Hash))
+	if status.ActiveProfile != "" {
+		desc += fmt.Sprintf(" (profile %s)", status.ActiveProfile)
+	}
+
+	desc += fmt.Sprintf(", %d outputs connected", len(xrandr.ConnectedOutputNames(status.Outputs)))
+
+	if !status.IsSaved {
+		desc += ", not saved"
+	}
+
+	if status.IsPaused {
+		desc += ", paused"
+	}
+
+	return desc
+}
@@ -0,0 +1,424 @@
+// Package config contains i3adc's user configuration, and the code to load and validate it.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/seeruk/i3adc/logging"
+	"github.com/seeruk/i3adc/logging/zap"
+	"github.com/seeruk/i3adc/state"
+)
+
+// EnvPath is the name of the environment variable that may be used to override the path of the
+// configuration file.
+const EnvPath = "I3ADC_CONFIG"
+
+// Layout strategies, used to decide how new layouts are created.
+const (
+	// StrategyClosest bases new layouts on the closest saved layout, if there is one.
+	StrategyClosest = "closest"
+	// StrategyRow always places the outputs of new layouts in a row, at their preferred modes.
+	StrategyRow = "row"
+)
+
+// Primary policies, used to decide which output is made primary in new layouts.
+const (
+	// PrimaryFirst makes the first connected output primary, in the order X reports them.
+	PrimaryFirst = "first"
+	// PrimaryLargest makes the output with the largest preferred mode primary.
+	PrimaryLargest = "largest"
+	// PrimaryInternal makes the internal panel (e.g. a laptop's display) primary.
+	PrimaryInternal = "internal"
+	// PrimaryExternal makes the first output that isn't an internal panel primary.
+	PrimaryExternal = "external"
+)
+
+// Hook events.
+const (
+	// HookPreApply happens just before a layout is applied.
+	HookPreApply = "pre-apply"
+	// HookPostApply happens just after a layout has been applied.
+	HookPostApply = "post-apply"
+	// HookLayoutCreated happens when a new layout has been created and saved.
+	HookLayoutCreated = "layout-created"
+	// HookLayoutUpdated happens when the user has changed an existing layout, and it's been saved.
+	HookLayoutUpdated = "layout-updated"
+	// HookLayoutSwitched happens when i3adc has switched to a different saved layout.
+	HookLayoutSwitched = "layout-switched"
+)
+
+// HookEvents contains every hook event.
+var HookEvents = []string{
+	HookPreApply,
+	HookPostApply,
+	HookLayoutCreated,
+	HookLayoutUpdated,
+	HookLayoutSwitched,
+}
+
+// Config is the root of i3adc's configuration.
+type Config struct {
+	Logging zap.Config `json:"logging"`
+	State   State      `json:"state"`
+	Events  Events     `json:"events"`
+	Layout  Layout     `json:"layout"`
+	Hooks   Hooks      `json:"hooks"`
+	Rules   []Rule     `json:"rules"`
+}
+
+// State contains the configuration of where i3adc stores it's state.
+type State struct {
+	// Path is the path of the database file. A leading "~/" is expanded to the home directory.
+	Path string `json:"path"`
+}
+
+// Events contains the configuration of the sources of events that make i3adc re-evaluate the
+// connected outputs.
+type Events struct {
+	// Settle is how long to wait for events to stop arriving before the outputs are looked at, so
+	// that a burst of events (e.g. from plugging in a dock) is handled once, after things settle.
+	Settle Duration `json:"settle"`
+
+	// Stabilise decides how long to wait for the outputs to finish connecting before they're
+	// looked at.
+	Stabilise Stabilise `json:"stabilise"`
+
+	I3     EventSource  `json:"i3"`
+	Logind LogindSource `json:"logind"`
+	Udev   EventSource  `json:"udev"`
+	Poll   PollSource   `json:"poll"`
+}
+
+// Stabilise contains the configuration of how i3adc waits for the outputs to stabilise after an
+// event, before deciding what to do. The outputs are read until two reads in a row agree, and every
+// connected output has an EDID and modes, or until the timeout expires.
+type Stabilise struct {
+	// Timeout is the longest to wait. If it's 0, the outputs are only read once.
+	Timeout Duration `json:"timeout"`
+	// Interval is how long to wait between reads.
+	Interval Duration `json:"interval"`
+}
+
+// EventSource contains the configuration common to all event sources.
+type EventSource struct {
+	Enabled bool `json:"enabled"`
+}
+
+// LogindSource contains the configuration of the logind event source, which tells i3adc when the
+// machine resumes from sleep.
+type LogindSource struct {
+	Enabled bool `json:"enabled"`
+	// Delay is how long to wait after resuming before the outputs are looked at, as they often take
+	// a moment to come back.
+	Delay Duration `json:"delay"`
+}
+
+// PollSource contains the configuration of the polling event source, which reads the outputs at an
+// interval, for setups that never report changes to them.
+type PollSource struct {
+	Enabled bool `json:"enabled"`
+	// Interval is how long to wait between polls after something changes.
+	Interval Duration `json:"interval"`
+	// MaxInterval is the longest to wait between polls, after nothing has changed for a while.
+	MaxInterval Duration `json:"max_interval"`
+}
+
+// Layout contains the configuration of how layouts are created.
+type Layout struct {
+	// Strategy is the strategy used to create new layouts.
+	Strategy string `json:"strategy"`
+	// Primary is the policy used to choose the primary output of new layouts, when there isn't
+	// already a primary output.
+	Primary string `json:"primary"`
+}
+
+// Hooks contains the configuration of the executables run when layouts change.
+type Hooks struct {
+	// Directory is a directory containing a sub-directory for each event (e.g. "post-apply"). Any
+	// executables in those are run when that event happens. A leading "~/" is expanded to the
+	// home directory.
+	Directory string `json:"directory"`
+	// Timeout is how long each hook may run for before it is killed.
+	Timeout Duration `json:"timeout"`
+	// PreApplyTimeout is how long the pre-apply hooks may run for, all together. Layouts aren't
+	// applied until they've finished, so this is kept short.
+	PreApplyTimeout Duration `json:"pre_apply_timeout"`
+	// Commands maps each event to a list of executables to run when it happens.
+	Commands map[string][]string `json:"commands"`
+}
+
+// levels are the valid logging levels.
+var levels = []logging.Level{
+	logging.DebugLevel, logging.InfoLevel, logging.WarnLevel, logging.ErrorLevel, logging.FatalLevel,
+}
+
+// Default returns the default configuration, which is used as the base of any configuration that
+// is loaded.
+func Default() Config {
+	return Config{
+		Logging: zap.Config{
+			Level:  logging.InfoLevel,
+			Output: zap.OutputStdout,
+			Format: zap.FormatAuto,
+			File: zap.FileConfig{
+				MaxSize:    10,
+				MaxBackups: 3,
+			},
+		},
+		Events: Events{
+			Settle: Duration(500 * time.Millisecond),
+			Stabilise: Stabilise{
+				Timeout:  Duration(2 * time.Second),
+				Interval: Duration(100 * time.Millisecond),
+			},
+			I3:     EventSource{Enabled: true},
+			Logind: LogindSource{Enabled: true, Delay: Duration(2 * time.Second)},
+			Poll: PollSource{
+				Interval:    Duration(time.Second),
+				MaxInterval: Duration(30 * time.Second),
+			},
+		},
+		Layout: Layout{
+			Strategy: StrategyClosest,
+			Primary:  PrimaryFirst,
+		},
+		Hooks: Hooks{
+			Timeout:         Duration(10 * time.Second),
+			PreApplyTimeout: Duration(2 * time.Second),
+		},
+	}
+}
+
+// Path returns the path of the configuration file. This is "config.json" in i3adc's local
+// directory, unless overridden by the environment.
+func Path() (string, error) {
+	if path := os.Getenv(EnvPath); path != "" {
+		return path, nil
+	}
+
+	localDir, err := state.LocalDirectory()
+	if err != nil {
+		return "", fmt.Errorf("config: failed to get local directory: %v", err)
+	}
+
+	return filepath.Join(localDir, "config.json"), nil
+}
+
+// Load reads the configuration file at the given path on top of the default configuration, then
+// validates it. If the file doesn't exist, the default configuration is returned.
+func Load(path string) (Config, error) {
+	config := Default()
+
+	bs, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, config.expandPaths()
+	}
+
+	if err != nil {
+		return config, fmt.Errorf("config: failed to read %s: %v", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(&config)
+	if err != nil {
+		return config, fmt.Errorf("config: failed to parse %s: %v", path, describeDecodeError(bs, err))
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return config, fmt.Errorf("config: invalid %s:\n  %v", path, err)
+	}
+
+	err = config.expandPaths()
+	if err != nil {
+		return config, fmt.Errorf("config: %v", err)
+	}
+
+	return config, nil
+}
+
+// Validate checks this configuration, returning an error describing every problem found with it.
+func (c Config) Validate() error {
+	var problems []string
+
+	problem := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if !isValidLevel(c.Logging.Level) {
+		problem("logging.level", "invalid level %q (must be one of %q)", c.Logging.Level, levels)
+	}
+
+	modules := make([]string, 0, len(c.Logging.Modules))
+	for module := range c.Logging.Modules {
+		modules = append(modules, module)
+	}
+
+	sort.Strings(modules)
+
+	for _, module := range modules {
+		level := c.Logging.Modules[module]
+		if !isValidLevel(level) {
+			problem("logging.modules."+module, "invalid level %q (must be one of %q)", level, levels)
+		}
+	}
+
+	switch c.Logging.Format {
+	case zap.FormatAuto, zap.FormatConsole, zap.FormatJSON:
+	default:
+		problem("logging.format", "invalid format %q (must be one of %q)", c.Logging.Format, []string{
+			zap.FormatAuto, zap.FormatConsole, zap.FormatJSON,
+		})
+	}
+
+	switch c.Logging.Output {
+	case zap.OutputStdout, zap.OutputStderr, zap.OutputFile, zap.OutputJournal:
+	default:
+		problem("logging.output", "invalid output %q (must be one of %q)", c.Logging.Output, []string{
+			zap.OutputStdout, zap.OutputStderr, zap.OutputFile, zap.OutputJournal,
+		})
+	}
+
+	if c.Logging.File.MaxSize < 0 {
+		problem("logging.file.max_size", "must not be negative")
+	}
+
+	if c.Logging.File.MaxBackups < 0 {
+		problem("logging.file.max_backups", "must not be negative")
+	}
+
+	switch c.Layout.Strategy {
+	case StrategyClosest, StrategyRow:
+	default:
+		problem("layout.strategy", "invalid strategy %q (must be one of %q)", c.Layout.Strategy, []string{
+			StrategyClosest, StrategyRow,
+		})
+	}
+
+	switch c.Layout.Primary {
+	case PrimaryFirst, PrimaryLargest, PrimaryInternal, PrimaryExternal:
+	default:
+		problem("layout.primary", "invalid policy %q (must be one of %q)", c.Layout.Primary, []string{
+			PrimaryFirst, PrimaryLargest, PrimaryInternal, PrimaryExternal,
+		})
+	}
+
+	if c.Events.Settle < 0 {
+		problem("events.settle", "must not be negative")
+	}
+
+	if c.Events.Stabilise.Timeout < 0 {
+		problem("events.stabilise.timeout", "must not be negative")
+	}
+
+	if c.Events.Stabilise.Interval <= 0 {
+		problem("events.stabilise.interval", "must be greater than 0")
+	}
+
+	if c.Events.Logind.Delay < 0 {
+		problem("events.logind.delay", "must not be negative")
+	}
+
+	if c.Events.Poll.Interval <= 0 {
+		problem("events.poll.interval", "must be greater than 0")
+	}
+
+	if c.Events.Poll.MaxInterval < c.Events.Poll.Interval {
+		problem("events.poll.max_interval", "must not be less than events.poll.interval")
+	}
+
+	if c.Hooks.Timeout <= 0 {
+		problem("hooks.timeout", "must be greater than 0")
+	}
+
+	if c.Hooks.PreApplyTimeout <= 0 {
+		problem("hooks.pre_apply_timeout", "must be greater than 0")
+	}
+
+	events := make([]string, 0, len(c.Hooks.Commands))
+	for event := range c.Hooks.Commands {
+		events = append(events, event)
+	}
+
+	// Sort the events so that problems are always reported in the same order.
+	sort.Strings(events)
+
+	for _, event := range events {
+		commands := c.Hooks.Commands[event]
+		if !contains(HookEvents, event) {
+			problem("hooks.commands", "unknown event %q (must be one of %q)", event, HookEvents)
+		}
+
+		for i, command := range commands {
+			if strings.TrimSpace(command) == "" {
+				problem(fmt.Sprintf("hooks.commands.%s[%d]", event, i), "must not be empty")
+			}
+		}
+	}
+
+	problems = append(problems, validateRules(c.Rules)...)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+// isValidLevel returns true if the given level is one of the known logging levels.
+func isValidLevel(level logging.Level) bool {
+	for _, valid := range levels {
+		if level == valid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expandPaths expands a leading "~/" in any paths in this configuration to the home directory.
+func (c *Config) expandPaths() error {
+	for _, path := range []*string{&c.State.Path, &c.Hooks.Directory, &c.Logging.File.Path} {
+		if !strings.HasPrefix(*path, "~/") {
+			continue
+		}
+
+		home, err := state.HomeDirectory()
+		if err != nil {
+			return err
+		}
+
+		*path = filepath.Join(home, (*path)[2:])
+	}
+
+	return nil
+}
+
+// describeDecodeError adds the line and column that a JSON decoding error occurred at, if known.
+func describeDecodeError(bs []byte, err error) error {
+	var offset int64
+
+	switch err := err.(type) {
+	case *json.SyntaxError:
+		offset = err.Offset
+	case *json.UnmarshalTypeError:
+		offset = err.Offset
+	default:
+		return err
+	}
+
+	line := 1 + bytes.Count(bs[:offset], []byte("\n"))
+	column := int(offset) - bytes.LastIndex(bs[:offset], []byte("\n"))
+
+	return fmt.Errorf("line %d, column %d: %v", line, column, err)
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seeruk/i3adc/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i3adc-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(dir, t.Name()[len("TestLoad/"):]+".json")
+
+		err := ioutil.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	t.Run("should return the defaults if the file doesn't exist", func(t *testing.T) {
+		config, err := Load(filepath.Join(dir, "missing.json"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, Default(), config)
+	})
+
+	t.Run("should load the file on top of the defaults", func(t *testing.T) {
+		config, err := Load(write(t, `{
+			"logging": {"level": "debug"},
+			"layout": {"primary": "internal"},
+			"hooks": {"timeout": "2s", "commands": {"post-apply": ["notify-send i3adc"]}},
+			"rules": [{"name": "dell", "match": {"manufacturer": "DEL"}, "rotation": "left"}]
+		}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, logging.DebugLevel, config.Logging.Level)
+		assert.Equal(t, Default().Logging.Output, config.Logging.Output)
+		assert.Equal(t, StrategyClosest, config.Layout.Strategy)
+		assert.Equal(t, PrimaryInternal, config.Layout.Primary)
+		assert.Equal(t, Duration(2*time.Second), config.Hooks.Timeout)
+		assert.Equal(t, []string{"notify-send i3adc"}, config.Hooks.Commands[HookPostApply])
+		assert.Equal(t, "left", config.Rules[0].Rotation)
+	})
+
+	t.Run("should expand the home directory in paths", func(t *testing.T) {
+		config, err := Load(write(t, `{"state": {"path": "~/i3adc.db"}}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(os.Getenv("HOME"), "i3adc.db"), config.State.Path)
+	})
+
+	t.Run("should report where syntax errors are", func(t *testing.T) {
+		_, err := Load(write(t, "{\n  \"logging\": {\n    \"level\": debug\n  }\n}"))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "line 3")
+	})
+
+	t.Run("should reject unknown fields", func(t *testing.T) {
+		_, err := Load(write(t, `{"layout": {"stratgey": "row"}}`))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "stratgey")
+	})
+
+	t.Run("should report every invalid value", func(t *testing.T) {
+		_, err := Load(write(t, `{
+			"logging": {"format": "xml"},
+			"layout": {"strategy": "random"},
+			"hooks": {"commands": {"post-aply": ["true"]}},
+			"rules": [
+				{"match": {}, "mode": "big"},
+				{"match": {"connector": "DP"}, "position": {"right_of": "laptop"}}
+			]
+		}`))
+
+		assert.Error(t, err)
+
+		for _, field := range []string{"logging.format", "layout.strategy", "hooks.commands", "rules[0].match", "rules[0].mode", "rules[1].position.right_of"} {
+			assert.Contains(t, err.Error(), field+": ")
+		}
+	})
+}
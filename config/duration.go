@@ -0,0 +1,34 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration that is written in configuration as a string, like "1.5s" or "500ms".
+type Duration time.Duration
+
+// MarshalJSON returns this duration as a JSON string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON parses a JSON string (e.g. "1.5s") into this duration.
+func (d *Duration) UnmarshalJSON(bs []byte) error {
+	var str string
+
+	err := json.Unmarshal(bs, &str)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s (must be a string, like \"1.5s\")", bs)
+	}
+
+	duration, err := time.ParseDuration(str)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q (must be like \"1.5s\")", str)
+	}
+
+	*d = Duration(duration)
+
+	return nil
+}
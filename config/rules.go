@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Rotations that may be used in rules.
+var rotations = []string{"normal", "left", "inverted", "right"}
+
+// Rule describes how a particular monitor should always be configured, instead of learning it's
+// configuration by observation.
+type Rule struct {
+	// Name identifies this rule, so that other rules can position their outputs relative to the
+	// output matched by this one.
+	Name string `json:"name"`
+	// Match decides which outputs this rule applies to.
+	Match Match `json:"match"`
+	// Enabled, if set, decides whether matching outputs are turned on or off.
+	Enabled *bool `json:"enabled,omitempty"`
+	// Primary, if set, decides whether matching outputs are primary.
+	Primary *bool `json:"primary,omitempty"`
+	// Mode is the mode to use for matching outputs, like "2560x1440".
+	Mode string `json:"mode,omitempty"`
+	// Rate is the refresh rate to use for matching outputs, in Hz.
+	Rate float64 `json:"rate,omitempty"`
+	// Rotation is the rotation to use for matching outputs (normal, left, inverted, or right).
+	Rotation string `json:"rotation,omitempty"`
+	// Scale is the scale to use for matching outputs, e.g. 2 to make everything half the size.
+	Scale float64 `json:"scale,omitempty"`
+	// Position is where to place matching outputs.
+	Position *Position `json:"position,omitempty"`
+	// Enforce applies this rule to saved layouts too, not just new ones.
+	Enforce bool `json:"enforce,omitempty"`
+}
+
+// Match decides which outputs a rule applies to. Every field that is set must match.
+type Match struct {
+	// Output is the name of the output, like "DP-1".
+	Output string `json:"output,omitempty"`
+	// Connector is the type of connector, like "eDP", "DP", or "HDMI".
+	Connector string `json:"connector,omitempty"`
+	// Manufacturer is the three letter manufacturer ID from the monitor's EDID, like "DEL".
+	Manufacturer string `json:"manufacturer,omitempty"`
+	// Model is the model name, or product code, from the monitor's EDID.
+	Model string `json:"model,omitempty"`
+	// Serial is the serial number from the monitor's EDID.
+	Serial string `json:"serial,omitempty"`
+}
+
+// IsEmpty returns true if no fields are set on this match.
+func (m Match) IsEmpty() bool {
+	return m == Match{}
+}
+
+// Position is where an output is placed. It's either an absolute position, or relative to the
+// output matched by another named rule.
+type Position struct {
+	X       int    `json:"x,omitempty"`
+	Y       int    `json:"y,omitempty"`
+	LeftOf  string `json:"left_of,omitempty"`
+	RightOf string `json:"right_of,omitempty"`
+	Above   string `json:"above,omitempty"`
+	Below   string `json:"below,omitempty"`
+}
+
+// Relative returns the relation, and name of the rule that this position is relative to. If it's
+// an absolute position, the relation will be empty.
+func (p Position) Relative() (relation string, name string) {
+	switch {
+	case p.LeftOf != "":
+		return "left_of", p.LeftOf
+	case p.RightOf != "":
+		return "right_of", p.RightOf
+	case p.Above != "":
+		return "above", p.Above
+	case p.Below != "":
+		return "below", p.Below
+	}
+
+	return "", ""
+}
+
+// validate checks this rule on it's own, returning a description of each problem found with it.
+func (r Rule) validate() []string {
+	var problems []string
+
+	problem := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if r.Match.IsEmpty() {
+		problem("match", "must set at least one of output, connector, manufacturer, model, or serial")
+	}
+
+	if r.Mode != "" {
+		var width, height int
+
+		_, err := fmt.Sscanf(r.Mode, "%dx%d", &width, &height)
+		if err != nil || width <= 0 || height <= 0 {
+			problem("mode", "invalid mode %q (must be like \"2560x1440\")", r.Mode)
+		}
+	}
+
+	if r.Rate < 0 {
+		problem("rate", "must not be negative")
+	}
+
+	if r.Scale < 0 {
+		problem("scale", "must not be negative")
+	}
+
+	if r.Rotation != "" && !contains(rotations, r.Rotation) {
+		problem("rotation", "invalid rotation %q (must be one of %q)", r.Rotation, rotations)
+	}
+
+	if r.Position != nil {
+		var relations int
+		for _, name := range []string{r.Position.LeftOf, r.Position.RightOf, r.Position.Above, r.Position.Below} {
+			if name != "" {
+				relations++
+			}
+		}
+
+		if relations > 1 {
+			problem("position", "must only set one of left_of, right_of, above, or below")
+		}
+
+		if relations > 0 && (r.Position.X != 0 || r.Position.Y != 0) {
+			problem("position", "must not set x or y along with a relative position")
+		}
+	}
+
+	return problems
+}
+
+// validateRules checks the given rules, including the references between them, returning a
+// description of each problem found with them.
+func validateRules(rules []Rule) []string {
+	var problems []string
+
+	names := make(map[string]bool)
+	for i, rule := range rules {
+		if rule.Name == "" {
+			continue
+		}
+
+		if names[rule.Name] {
+			problems = append(problems, fmt.Sprintf("rules[%d].name: duplicate name %q", i, rule.Name))
+		}
+
+		names[rule.Name] = true
+	}
+
+	for i, rule := range rules {
+		for _, problem := range rule.validate() {
+			problems = append(problems, fmt.Sprintf("rules[%d].%s", i, problem))
+		}
+
+		if rule.Position == nil {
+			continue
+		}
+
+		relation, name := rule.Position.Relative()
+		if name == "" {
+			continue
+		}
+
+		if name == rule.Name {
+			problems = append(problems, fmt.Sprintf("rules[%d].position.%s: must not refer to itself", i, relation))
+		} else if !names[name] {
+			problems = append(problems, fmt.Sprintf("rules[%d].position.%s: unknown rule %q", i, relation, name))
+		}
+	}
+
+	return problems
+}
+
+// contains returns true if the given value is in the given values.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
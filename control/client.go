@@ -0,0 +1,177 @@
+package control
+
+import (
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+
+	"github.com/seeruk/i3adc/xrandr"
+)
+
+// Client is a client for the control socket of a running i3adc daemon. It's methods mirror those
+// of xrandr.Manager, so that either can be used by commands.
+type Client struct {
+	client *rpc.Client
+}
+
+// Dial connects to the control socket at the given path. An error is returned if no daemon is
+// listening on it.
+func Dial(path string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client: jsonrpc.NewClient(conn),
+	}, nil
+}
+
+// Close closes the connection to the daemon.
+func (c *Client) Close() error {
+	return c.client.Close()
+}
+
+// Status returns the status of the currently connected outputs.
+func (c *Client) Status() (xrandr.Status, error) {
+	var reply xrandr.Status
+	err := c.call("Status", Empty{}, &reply)
+
+	return reply, err
+}
+
+// Layouts returns information about every saved layout.
+func (c *Client) Layouts() ([]xrandr.LayoutInfo, error) {
+	var reply []xrandr.LayoutInfo
+	err := c.call("List", Empty{}, &reply)
+
+	return reply, err
+}
+
+// Layout returns information about a single saved layout.
+func (c *Client) Layout(ref string) (xrandr.LayoutInfo, error) {
+	var reply xrandr.LayoutInfo
+	err := c.call("Show", RefArgs{Ref: ref}, &reply)
+
+	return reply, err
+}
+
+// Apply applies a saved layout, or profile.
+func (c *Client) Apply(ref string) error {
+	return c.call("Apply", RefArgs{Ref: ref}, &Empty{})
+}
+
+// DeleteLayout deletes a saved layout, returning it's hash.
+func (c *Client) DeleteLayout(ref string) (string, error) {
+	var reply DeleteReply
+	err := c.call("Delete", RefArgs{Ref: ref}, &reply)
+
+	return reply.Hash, err
+}
+
+// Save saves the current configuration as the layout for the connected outputs, returning it's
+// hash. If a profile name is given, the layout is saved as that profile too.
+func (c *Client) Save(profile string) (string, error) {
+	var reply SaveReply
+	err := c.call("Save", SaveArgs{Profile: profile}, &reply)
+
+	return reply.Hash, err
+}
+
+// Pause stops the daemon from reacting to output events.
+func (c *Client) Pause() error {
+	return c.call("Pause", Empty{}, &Empty{})
+}
+
+// Resume allows the daemon to react to output events again.
+func (c *Client) Resume() error {
+	return c.call("Resume", Empty{}, &Empty{})
+}
+
+// Reload makes the daemon re-evaluate the connected outputs.
+func (c *Client) Reload() error {
+	return c.call("Reload", Empty{}, &Empty{})
+}
+
+// Revisions returns the history of a layout, along with it's full hash.
+func (c *Client) Revisions(ref string) (string, []xrandr.Revision, error) {
+	var reply RevisionsReply
+	err := c.call("Revisions", RefArgs{Ref: ref}, &reply)
+
+	return reply.Hash, reply.Revisions, err
+}
+
+// DiffRevisions returns the differences between two revisions of a layout.
+func (c *Client) DiffRevisions(ref string, from, to int) ([]string, error) {
+	var reply []string
+	err := c.call("Diff", DiffArgs{Ref: ref, From: from, To: to}, &reply)
+
+	return reply, err
+}
+
+// Revert restores a previous revision of a layout, reporting whether or not it was applied.
+func (c *Client) Revert(ref string, revision int) (bool, error) {
+	var reply RevertReply
+	err := c.call("Revert", RevertArgs{Ref: ref, Revision: revision}, &reply)
+
+	return reply.Applied, err
+}
+
+// Profiles returns every saved profile, and the name of the active one.
+func (c *Client) Profiles() ([]xrandr.Profile, string, error) {
+	var reply ProfilesReply
+	err := c.call("Profiles", Empty{}, &reply)
+
+	return reply.Profiles, reply.Active, err
+}
+
+// SaveProfile saves a layout as a profile.
+func (c *Client) SaveProfile(name, ref string) (xrandr.Profile, error) {
+	var reply xrandr.Profile
+	err := c.call("SaveProfile", ProfileArgs{Name: name, Ref: ref}, &reply)
+
+	return reply, err
+}
+
+// ActivateProfile applies a profile.
+func (c *Client) ActivateProfile(name string) error {
+	return c.call("ActivateProfile", ProfileArgs{Name: name}, &Empty{})
+}
+
+// DeleteProfile deletes a profile.
+func (c *Client) DeleteProfile(name string) error {
+	return c.call("DeleteProfile", ProfileArgs{Name: name}, &Empty{})
+}
+
+// Commands returns the i3 commands set for a layout or profile.
+func (c *Client) Commands(ref string) (xrandr.Commands, error) {
+	var reply xrandr.Commands
+	err := c.call("Commands", RefArgs{Ref: ref}, &reply)
+
+	return reply, err
+}
+
+// SetCommands sets the i3 commands for a layout or profile.
+func (c *Client) SetCommands(ref string, commands []string) (xrandr.Commands, error) {
+	var reply xrandr.Commands
+	err := c.call("SetCommands", SetCommandsArgs{Ref: ref, Commands: commands}, &reply)
+
+	return reply, err
+}
+
+// Shutdown asks the daemon to exit gracefully. The daemon may close the connection before replying,
+// so the lost connection isn't treated as an error.
+func (c *Client) Shutdown() error {
+	err := c.call("Shutdown", Empty{}, &Empty{})
+	if err == rpc.ErrShutdown || err == io.ErrUnexpectedEOF || err == io.EOF {
+		return nil
+	}
+
+	return err
+}
+
+// call calls the given method of the daemon's RPC service.
+func (c *Client) call(method string, args interface{}, reply interface{}) error {
+	return c.client.Call(ServiceName+"."+method, args, reply)
+}
@@ -0,0 +1,95 @@
+// Package control provides a Unix socket with a small JSON-RPC API, allowing other processes (such
+// as i3adc's own commands) to control a running i3adc daemon.
+package control
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/seeruk/i3adc/state"
+	"github.com/seeruk/i3adc/xrandr"
+)
+
+// ServiceName is the name that the RPC service is registered under. Method names are prefixed with
+// it, e.g. "Daemon.Status".
+const ServiceName = "Daemon"
+
+// SocketPath returns the path of the control socket for the current user.
+func SocketPath() (string, error) {
+	localDir, err := state.LocalDirectory()
+	if err != nil {
+		return "", fmt.Errorf("control: failed to get local directory: %v", err)
+	}
+
+	return filepath.Join(localDir, "i3adc.sock"), nil
+}
+
+// Empty is used as the arguments or reply of methods that don't need any.
+type Empty struct{}
+
+// RefArgs are the arguments of methods that operate on a single layout or profile.
+type RefArgs struct {
+	Ref string `json:"ref"`
+}
+
+// SaveArgs are the arguments of the Save method.
+type SaveArgs struct {
+	// Profile, if set, is the name of a profile to save the current layout as, too.
+	Profile string `json:"profile,omitempty"`
+}
+
+// SaveReply is the reply of the Save method.
+type SaveReply struct {
+	Hash string `json:"hash"`
+}
+
+// DeleteReply is the reply of the Delete method.
+type DeleteReply struct {
+	Hash string `json:"hash"`
+}
+
+// RevisionsReply is the reply of the Revisions method.
+type RevisionsReply struct {
+	Hash      string            `json:"hash"`
+	Revisions []xrandr.Revision `json:"revisions"`
+}
+
+// DiffArgs are the arguments of the Diff method.
+type DiffArgs struct {
+	Ref  string `json:"ref"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+// RevertArgs are the arguments of the Revert method.
+type RevertArgs struct {
+	Ref      string `json:"ref"`
+	Revision int    `json:"revision"`
+}
+
+// RevertReply is the reply of the Revert method.
+type RevertReply struct {
+	Applied bool `json:"applied"`
+}
+
+// ProfileArgs are the arguments of the methods that operate on profiles.
+type ProfileArgs struct {
+	Name string `json:"name"`
+	Ref  string `json:"ref,omitempty"`
+}
+
+// ProfilesReply is the reply of the Profiles method.
+type ProfilesReply struct {
+	Profiles []xrandr.Profile `json:"profiles"`
+	Active   string           `json:"active"`
+}
+
+// SetCommandsArgs are the arguments of the SetCommands method.
+type SetCommandsArgs struct {
+	Ref      string   `json:"ref"`
+	Commands []string `json:"commands"`
+}
+
+// dialTimeout is how long clients will wait to connect to the control socket.
+const dialTimeout = time.Second
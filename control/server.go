@@ -0,0 +1,133 @@
+package control
+
+import (
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+
+	"github.com/seeruk/i3adc/logging"
+)
+
+// Server is a background thread that listens on the control socket, serving the RPC service to
+// each connection it accepts.
+type Server struct {
+	sync.Mutex
+
+	conns    map[net.Conn]struct{}
+	listener net.Listener
+	logger   logging.Logger
+	path     string
+	server   *rpc.Server
+	stopping bool
+}
+
+// NewServer returns a new control server instance, that will listen on the given socket path.
+func NewServer(path string, service *Service, logger logging.Logger) (*Server, error) {
+	logger = logger.With("module", "control/server")
+
+	server := rpc.NewServer()
+
+	err := server.RegisterName(ServiceName, service)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		conns:  make(map[net.Conn]struct{}),
+		logger: logger,
+		path:   path,
+		server: server,
+	}, nil
+}
+
+// Start begins listening on the control socket, and serving connections, until Stop is called.
+func (s *Server) Start() error {
+	// A socket may be left behind if i3adc didn't exit cleanly. If another instance were actually
+	// running, we wouldn't have got this far, as it would still hold the instance lock.
+	err := os.Remove(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	listener, err := net.Listen("unix", s.path)
+	if err != nil {
+		return err
+	}
+
+	// Only the current user should be able to control i3adc.
+	err = os.Chmod(s.path, 0600)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	s.Lock()
+	if s.stopping {
+		s.Unlock()
+		listener.Close()
+		return nil
+	}
+
+	s.listener = listener
+	s.Unlock()
+
+	s.logger.Infow("thread started", "path", s.path)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.Lock()
+			stopping := s.stopping
+			s.Unlock()
+
+			if stopping {
+				s.logger.Info("thread stopped")
+				return nil
+			}
+
+			return err
+		}
+
+		s.Lock()
+		s.conns[conn] = struct{}{}
+		s.Unlock()
+
+		go s.serve(conn)
+	}
+}
+
+// Stop stops listening on the control socket, and closes any open connections.
+func (s *Server) Stop() error {
+	s.logger.Info("thread stopping")
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.stopping = true
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	if s.listener == nil {
+		return nil
+	}
+
+	// Closing a Unix listener also removes it's socket file.
+	return s.listener.Close()
+}
+
+// serve serves RPC requests made over the given connection until it's closed.
+func (s *Server) serve(conn net.Conn) {
+	s.logger.Debug("client connected")
+
+	s.server.ServeCodec(jsonrpc.NewServerCodec(conn))
+
+	s.Lock()
+	delete(s.conns, conn)
+	s.Unlock()
+
+	s.logger.Debug("client disconnected")
+}
@@ -0,0 +1,121 @@
+package control
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seeruk/i3adc/config"
+	"github.com/seeruk/i3adc/daemon"
+	"github.com/seeruk/i3adc/logging/noop"
+	"github.com/seeruk/i3adc/state/memory"
+	"github.com/seeruk/i3adc/xrandr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i3adc-control")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "i3adc.sock")
+
+	store := xrandr.NewStore(memory.NewBackend())
+	manager := xrandr.NewManager(store, nil, nil, nil, nil, config.Default(), noop.NewLogger())
+
+	shutdownCh := make(chan struct{}, 1)
+
+	server, err := NewServer(path, NewService(manager, func() { shutdownCh <- struct{}{} }), noop.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+
+	done := daemon.NewBackgroundThread(ctx, server)
+
+	client := dialWithRetry(t, path)
+	defer client.Close()
+
+	t.Run("should proxy calls to the layout manager", func(t *testing.T) {
+		err := store.SaveProfile(xrandr.Profile{Name: "desk", Hash: "0123456789abcdef0123456789abcdef"})
+		assert.NoError(t, err)
+
+		profiles, active, err := client.Profiles()
+		assert.NoError(t, err)
+		assert.Equal(t, "", active)
+		assert.Len(t, profiles, 1)
+		assert.Equal(t, "desk", profiles[0].Name)
+
+		assert.NoError(t, client.DeleteProfile("desk"))
+	})
+
+	t.Run("should set i3 commands for a profile", func(t *testing.T) {
+		err := store.SaveProfile(xrandr.Profile{Name: "talk", Hash: "0123456789abcdef0123456789abcdef"})
+		assert.NoError(t, err)
+
+		_, err = client.SetCommands("talk", []string{"bar mode hide"})
+		assert.NoError(t, err)
+
+		commands, err := client.Commands("talk")
+		assert.NoError(t, err)
+		assert.Equal(t, "talk", commands.Profile)
+		assert.Equal(t, []string{"bar mode hide"}, commands.Commands)
+
+		assert.NoError(t, client.DeleteProfile("talk"))
+	})
+
+	t.Run("should return errors from the layout manager", func(t *testing.T) {
+		err := client.DeleteProfile("desk")
+		assert.EqualError(t, err, xrandr.ErrProfileNotFound.Error())
+	})
+
+	t.Run("should ask the daemon to shut down", func(t *testing.T) {
+		assert.NoError(t, client.Shutdown())
+
+		select {
+		case <-shutdownCh:
+		default:
+			t.Error("expected shutdown to be requested")
+		}
+	})
+
+	t.Run("should remove the socket when stopped", func(t *testing.T) {
+		cfn()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected server to stop")
+		}
+
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+// dialWithRetry connects to the control socket at the given path, waiting for the server to start.
+func dialWithRetry(t *testing.T, path string) *Client {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		client, err := Dial(path)
+		if err == nil {
+			return client
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("failed to connect to control socket: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
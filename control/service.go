@@ -0,0 +1,140 @@
+package control
+
+import (
+	"errors"
+
+	"github.com/seeruk/i3adc/xrandr"
+)
+
+// Service is the RPC service exposed over the control socket. Each exported method is available
+// as an RPC method, and simply delegates to the layout manager that the daemon uses.
+type Service struct {
+	manager  *xrandr.Manager
+	shutdown func()
+}
+
+// NewService returns a new RPC service instance. The given shutdown function is called when the
+// daemon is asked to exit, and may be nil if that isn't supported.
+func NewService(manager *xrandr.Manager, shutdown func()) *Service {
+	return &Service{
+		manager:  manager,
+		shutdown: shutdown,
+	}
+}
+
+// Status returns the status of the currently connected outputs.
+func (s *Service) Status(_ Empty, reply *xrandr.Status) (err error) {
+	*reply, err = s.manager.Status()
+	return err
+}
+
+// List returns information about every saved layout.
+func (s *Service) List(_ Empty, reply *[]xrandr.LayoutInfo) (err error) {
+	*reply, err = s.manager.Layouts()
+	return err
+}
+
+// Show returns information about a single saved layout.
+func (s *Service) Show(args RefArgs, reply *xrandr.LayoutInfo) (err error) {
+	*reply, err = s.manager.Layout(args.Ref)
+	return err
+}
+
+// Apply applies a saved layout, or profile.
+func (s *Service) Apply(args RefArgs, _ *Empty) error {
+	return s.manager.Apply(args.Ref)
+}
+
+// Delete deletes a saved layout.
+func (s *Service) Delete(args RefArgs, reply *DeleteReply) (err error) {
+	reply.Hash, err = s.manager.DeleteLayout(args.Ref)
+	return err
+}
+
+// Save saves the current configuration as the layout for the connected outputs, and optionally
+// as a profile too.
+func (s *Service) Save(args SaveArgs, reply *SaveReply) (err error) {
+	reply.Hash, err = s.manager.Save(args.Profile)
+	return err
+}
+
+// Pause stops the daemon from reacting to output events.
+func (s *Service) Pause(_ Empty, _ *Empty) error {
+	s.manager.Pause()
+	return nil
+}
+
+// Resume allows the daemon to react to output events again.
+func (s *Service) Resume(_ Empty, _ *Empty) error {
+	s.manager.Resume()
+	return nil
+}
+
+// Reload re-evaluates the connected outputs, applying their saved layout.
+func (s *Service) Reload(_ Empty, _ *Empty) error {
+	return s.manager.Reload()
+}
+
+// Revisions returns the history of a layout.
+func (s *Service) Revisions(args RefArgs, reply *RevisionsReply) (err error) {
+	reply.Hash, reply.Revisions, err = s.manager.Revisions(args.Ref)
+	return err
+}
+
+// Diff returns the differences between two revisions of a layout.
+func (s *Service) Diff(args DiffArgs, reply *[]string) (err error) {
+	*reply, err = s.manager.DiffRevisions(args.Ref, args.From, args.To)
+	return err
+}
+
+// Revert restores a previous revision of a layout.
+func (s *Service) Revert(args RevertArgs, reply *RevertReply) (err error) {
+	reply.Applied, err = s.manager.Revert(args.Ref, args.Revision)
+	return err
+}
+
+// Profiles returns every saved profile, and the name of the active one.
+func (s *Service) Profiles(_ Empty, reply *ProfilesReply) (err error) {
+	reply.Profiles, reply.Active, err = s.manager.Profiles()
+	return err
+}
+
+// SaveProfile saves a layout as a profile.
+func (s *Service) SaveProfile(args ProfileArgs, reply *xrandr.Profile) (err error) {
+	*reply, err = s.manager.SaveProfile(args.Name, args.Ref)
+	return err
+}
+
+// ActivateProfile applies a profile.
+func (s *Service) ActivateProfile(args ProfileArgs, _ *Empty) error {
+	return s.manager.ActivateProfile(args.Name)
+}
+
+// DeleteProfile deletes a profile.
+func (s *Service) DeleteProfile(args ProfileArgs, _ *Empty) error {
+	return s.manager.DeleteProfile(args.Name)
+}
+
+// Commands returns the i3 commands set for a layout or profile.
+func (s *Service) Commands(args RefArgs, reply *xrandr.Commands) (err error) {
+	*reply, err = s.manager.Commands(args.Ref)
+	return err
+}
+
+// SetCommands sets the i3 commands for a layout or profile.
+func (s *Service) SetCommands(args SetCommandsArgs, reply *xrandr.Commands) (err error) {
+	*reply, err = s.manager.SetCommands(args.Ref, args.Commands)
+	return err
+}
+
+// Shutdown asks the daemon to exit gracefully. The daemon stops in the background, so it may still
+// be running for a moment after this returns.
+func (s *Service) Shutdown(_ Empty, _ *Empty) error {
+	if s.shutdown == nil {
+		return errors.New("control: shutdown is not supported")
+	}
+
+	s.shutdown()
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seeruk/i3adc/logging"
+)
+
+// RestartPolicy decides whether a supervised thread is started again after it stops on it's own.
+type RestartPolicy int
+
+// Possible restart policies.
+const (
+	// RestartNever leaves a thread stopped, however it stopped.
+	RestartNever RestartPolicy = iota
+	// RestartOnFailure starts a thread again if it stopped with an error.
+	RestartOnFailure
+	// RestartAlways starts a thread again however it stopped.
+	RestartAlways
+)
+
+// String returns the name of the restart policy.
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	}
+
+	return "unknown"
+}
+
+// Possible statuses of a supervised thread.
+const (
+	StatusStarting = "starting"
+	StatusRunning  = "running"
+	StatusBackoff  = "backoff"
+	StatusStopped  = "stopped"
+	StatusFailed   = "failed"
+)
+
+// Default backoff between restarts of a failing thread. The backoff doubles with each consecutive
+// failure, up to the maximum.
+const (
+	DefaultMinBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// ThreadSpec describes a thread for a Supervisor to run.
+type ThreadSpec struct {
+	// Name identifies the thread in logs, errors, and states.
+	Name string
+	// New creates the thread. It's called each time the thread is (re)started, so that a thread
+	// never has to support being started more than once.
+	New func() Thread
+	// Policy decides whether the thread is restarted when it stops on it's own.
+	Policy RestartPolicy
+	// MaxRestarts is how many times in a row the thread may be restarted after failing before it
+	// is considered to have failed permanently. 0 means there's no limit.
+	MaxRestarts int
+	// Critical threads take the rest of the group with them when they stop for good, whether they
+	// failed or not.
+	Critical bool
+}
+
+// ThreadState is a snapshot of the state of a supervised thread.
+type ThreadState struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Policy    string    `json:"policy"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+	StartedAt time.Time `json:"started_at,omitempty"`
+}
+
+// Errors is a list of errors from several threads, reported as one.
+type Errors []error
+
+// Error implements the error interface for Errors.
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Supervisor runs a named group of threads, restarting them according to their restart policies,
+// and stopping the whole group if a critical thread stops for good.
+type Supervisor struct {
+	name   string
+	logger logging.Logger
+
+	// MinBackoff and MaxBackoff bound how long to wait before restarting a thread.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// OnChange, if set, is called with the new state of a thread whenever it changes. It may be
+	// called from several goroutines at once.
+	OnChange func(state ThreadState)
+
+	mu     sync.Mutex
+	specs  []ThreadSpec
+	states map[string]*ThreadState
+	errs   Errors
+}
+
+// NewSupervisor returns a new, empty Supervisor for the group of threads with the given name.
+func NewSupervisor(name string, logger logging.Logger) *Supervisor {
+	return &Supervisor{
+		name:       name,
+		logger:     logger.With("module", "daemon/supervisor", "group", name),
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+		states:     make(map[string]*ThreadState),
+	}
+}
+
+// Add adds a thread to the group. Threads must be added before the group is run.
+func (s *Supervisor) Add(spec ThreadSpec) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.specs = append(s.specs, spec)
+	s.states[spec.Name] = &ThreadState{
+		Name:   spec.Name,
+		Status: StatusStarting,
+		Policy: spec.Policy.String(),
+	}
+}
+
+// Run starts every thread in the group, and blocks until they've all stopped. The group is stopped
+// when the given context is cancelled, or when a critical thread stops for good. The errors from
+// any threads that failed permanently are returned.
+func (s *Supervisor) Run(ctx context.Context) error {
+	ctx, cfn := context.WithCancel(ctx)
+	defer cfn()
+
+	s.mu.Lock()
+	specs := append([]ThreadSpec(nil), s.specs...)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+
+	for _, spec := range specs {
+		wg.Add(1)
+
+		go func(spec ThreadSpec) {
+			defer wg.Done()
+
+			s.supervise(ctx, spec)
+
+			if spec.Critical && ctx.Err() == nil {
+				s.logger.Infow("critical thread stopped, stopping group", "thread", spec.Name)
+				cfn()
+			}
+		}(spec)
+	}
+
+	wg.Wait()
+
+	return s.Err()
+}
+
+// Err returns the errors from any threads that have failed permanently, or nil if there are none.
+func (s *Supervisor) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.errs) == 0 {
+		return nil
+	}
+
+	return append(Errors(nil), s.errs...)
+}
+
+// States returns the current state of each thread in the group, in the order they were added.
+func (s *Supervisor) States() []ThreadState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make([]ThreadState, 0, len(s.specs))
+	for _, spec := range s.specs {
+		states = append(states, *s.states[spec.Name])
+	}
+
+	return states
+}
+
+// supervise runs the thread described by the given spec until it stops for good, or the given
+// context is cancelled.
+func (s *Supervisor) supervise(ctx context.Context, spec ThreadSpec) {
+	backoff := s.MinBackoff
+	failures := 0
+
+	for {
+		startedAt := time.Now()
+
+		s.update(spec.Name, func(state *ThreadState) {
+			state.Status = StatusRunning
+			state.StartedAt = startedAt
+		})
+
+		err := <-NewBackgroundThread(ctx, spec.New())
+
+		if ctx.Err() != nil {
+			s.update(spec.Name, func(state *ThreadState) {
+				state.Status = StatusStopped
+			})
+
+			return
+		}
+
+		// A thread that ran for a good while before failing isn't failing repeatedly, so it gets to
+		// start over.
+		if time.Since(startedAt) > s.MaxBackoff {
+			backoff = s.MinBackoff
+			failures = 0
+		}
+
+		if err != nil {
+			failures++
+			s.logger.Errorw("thread stopped with an error", "thread", spec.Name, "error", err)
+		}
+
+		restart := spec.Policy == RestartAlways || (spec.Policy == RestartOnFailure && err != nil)
+		if restart && spec.MaxRestarts > 0 && failures > spec.MaxRestarts {
+			s.logger.Errorw("thread failed too many times, giving up", "thread", spec.Name)
+			restart = false
+		}
+
+		if !restart {
+			s.update(spec.Name, func(state *ThreadState) {
+				state.Status = StatusStopped
+
+				if err != nil {
+					state.Status = StatusFailed
+					state.LastError = err.Error()
+					s.errs = append(s.errs, fmt.Errorf("daemon: %s thread: %v", spec.Name, err))
+				}
+			})
+
+			return
+		}
+
+		s.update(spec.Name, func(state *ThreadState) {
+			state.Status = StatusBackoff
+			state.Restarts++
+
+			if err != nil {
+				state.LastError = err.Error()
+			}
+		})
+
+		s.logger.Infow("restarting thread", "thread", spec.Name, "backoff", backoff.String())
+
+		select {
+		case <-ctx.Done():
+			s.update(spec.Name, func(state *ThreadState) {
+				state.Status = StatusStopped
+			})
+
+			return
+		case <-time.After(backoff):
+		}
+
+		if err != nil {
+			backoff *= 2
+			if backoff > s.MaxBackoff {
+				backoff = s.MaxBackoff
+			}
+		}
+	}
+}
+
+// update calls the given function with the state of the thread with the given name, while holding
+// the lock, and then reports the new state.
+func (s *Supervisor) update(name string, fn func(state *ThreadState)) {
+	s.mu.Lock()
+	fn(s.states[name])
+	state := *s.states[name]
+	s.mu.Unlock()
+
+	if s.OnChange != nil {
+		s.OnChange(state)
+	}
+}
@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seeruk/i3adc/logging/noop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupervisor(t *testing.T) {
+	t.Run("should restart failing threads until they run out of restarts", func(t *testing.T) {
+		var starts int32
+
+		supervisor := newTestSupervisor()
+		supervisor.Add(ThreadSpec{
+			Name:        "failing",
+			New:         func() Thread { return &funcThread{start: countStarts(&starts, errors.New("boom"))} },
+			Policy:      RestartOnFailure,
+			MaxRestarts: 2,
+		})
+
+		err := supervisor.Run(context.Background())
+
+		assert.EqualError(t, err, "daemon: failing thread: boom")
+		assert.Equal(t, int32(3), atomic.LoadInt32(&starts))
+
+		states := supervisor.States()
+		assert.Equal(t, StatusFailed, states[0].Status)
+		assert.Equal(t, 2, states[0].Restarts)
+		assert.Equal(t, "boom", states[0].LastError)
+	})
+
+	t.Run("should not restart threads that stop cleanly when restarting on failure", func(t *testing.T) {
+		var starts int32
+
+		supervisor := newTestSupervisor()
+		supervisor.Add(ThreadSpec{
+			Name:   "clean",
+			New:    func() Thread { return &funcThread{start: countStarts(&starts, nil)} },
+			Policy: RestartOnFailure,
+		})
+
+		assert.NoError(t, supervisor.Run(context.Background()))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&starts))
+		assert.Equal(t, StatusStopped, supervisor.States()[0].Status)
+	})
+
+	t.Run("should not restart threads with the never policy", func(t *testing.T) {
+		var starts int32
+
+		supervisor := newTestSupervisor()
+		supervisor.Add(ThreadSpec{
+			Name:   "never",
+			New:    func() Thread { return &funcThread{start: countStarts(&starts, errors.New("boom"))} },
+			Policy: RestartNever,
+		})
+
+		assert.Error(t, supervisor.Run(context.Background()))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&starts))
+	})
+
+	t.Run("should stop the group when a critical thread stops for good", func(t *testing.T) {
+		supervisor := newTestSupervisor()
+		supervisor.Add(ThreadSpec{
+			Name:     "critical",
+			New:      func() Thread { return &funcThread{start: func() error { return errors.New("boom") }} },
+			Critical: true,
+		})
+		supervisor.Add(ThreadSpec{
+			Name:   "blocking",
+			New:    newBlockingThread,
+			Policy: RestartAlways,
+		})
+
+		done := make(chan error, 1)
+		go func() {
+			done <- supervisor.Run(context.Background())
+		}()
+
+		select {
+		case err := <-done:
+			assert.EqualError(t, err, "daemon: critical thread: boom")
+		case <-time.After(time.Second):
+			t.Fatal("expected group to stop")
+		}
+
+		states := supervisor.States()
+		assert.Equal(t, StatusFailed, states[0].Status)
+		assert.Equal(t, StatusStopped, states[1].Status)
+	})
+
+	t.Run("should stop every thread when the context is cancelled", func(t *testing.T) {
+		ctx, cfn := context.WithCancel(context.Background())
+
+		supervisor := newTestSupervisor()
+		supervisor.Add(ThreadSpec{Name: "a", New: newBlockingThread, Policy: RestartAlways})
+		supervisor.Add(ThreadSpec{Name: "b", New: newBlockingThread, Policy: RestartAlways})
+
+		done := make(chan error, 1)
+		go func() {
+			done <- supervisor.Run(ctx)
+		}()
+
+		cfn()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("expected group to stop")
+		}
+
+		for _, state := range supervisor.States() {
+			assert.Equal(t, StatusStopped, state.Status)
+		}
+	})
+}
+
+// newTestSupervisor returns a supervisor that doesn't wait long between restarts.
+func newTestSupervisor() *Supervisor {
+	supervisor := NewSupervisor("test", noop.NewLogger())
+	supervisor.MinBackoff = time.Millisecond
+	supervisor.MaxBackoff = 10 * time.Millisecond
+
+	return supervisor
+}
+
+// countStarts returns a start function that counts how many times it's called, and returns the
+// given error.
+func countStarts(starts *int32, err error) func() error {
+	return func() error {
+		atomic.AddInt32(starts, 1)
+		return err
+	}
+}
+
+// funcThread is a Thread that runs the given function when started.
+type funcThread struct {
+	start func() error
+	stop  func() error
+}
+
+// Start runs the thread's start function.
+func (t *funcThread) Start() error {
+	return t.start()
+}
+
+// Stop runs the thread's stop function, if it has one.
+func (t *funcThread) Stop() error {
+	if t.stop == nil {
+		return nil
+	}
+
+	return t.stop()
+}
+
+// newBlockingThread returns a thread that runs until it's stopped.
+func newBlockingThread() Thread {
+	done := make(chan struct{})
+
+	return &funcThread{
+		start: func() error {
+			<-done
+			return nil
+		},
+		stop: func() error {
+			close(done)
+			return nil
+		},
+	}
+}
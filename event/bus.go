@@ -0,0 +1,308 @@
+package event
+
+import (
+	"sync"
+
+	"github.com/seeruk/i3adc/logging"
+)
+
+// DropPolicy decides what happens to an event published to a subscriber whose buffer is full.
+// Publishing never waits for a subscriber, so that one slow subscriber can't hold up the others.
+type DropPolicy int
+
+// Possible drop policies.
+const (
+	// DropOldest drops the oldest buffered event to make room for the new one. It suits subscribers
+	// that only care about the latest state, like the xrandr thread.
+	DropOldest DropPolicy = iota
+	// DropNewest drops the new event, keeping the buffered ones. It suits subscribers that care more
+	// about what happened first.
+	DropNewest
+)
+
+// String returns the name of this policy.
+func (p DropPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop-oldest"
+	case DropNewest:
+		return "drop-newest"
+	default:
+		return "unknown"
+	}
+}
+
+// Bus passes published events on to every subscriber that wants them. Each subscriber has it's own
+// buffer, and drop policy for when that buffer is full. A nil bus accepts events, and drops them.
+// It's safe for concurrent use.
+type Bus struct {
+	logger logging.Logger
+
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewBus returns a new event bus, with no subscribers.
+func NewBus(logger logging.Logger) *Bus {
+	logger = logger.With("module", "event/bus")
+
+	return &Bus{
+		logger: logger,
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe returns a new subscription to this bus, with the given name (used in logs), that
+// buffers up to the given number of events, dropping events according to the given policy when
+// it's full. If any types are given, only events of those types are received.
+func (b *Bus) Subscribe(name string, buffer int, policy DropPolicy, types ...Type) *Subscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+
+	sub := &Subscription{
+		bus:    b,
+		name:   name,
+		policy: policy,
+		ch:     make(chan Event, buffer),
+	}
+
+	if len(types) > 0 {
+		sub.types = make(map[Type]bool, len(types))
+		for _, typ := range types {
+			sub.types[typ] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.ch)
+		return sub
+	}
+
+	b.subs[sub] = struct{}{}
+
+	b.logger.Debugw("subscribed", "subscriber", name, "buffer", buffer, "policy", policy.String())
+
+	return sub
+}
+
+// Publish passes the given event on to every subscriber that wants it, without waiting for any of
+// them.
+func (b *Bus) Publish(evt Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		if !sub.wants(evt) {
+			continue
+		}
+
+		dropped, ok := sub.deliver(evt)
+		if ok {
+			continue
+		}
+
+		// Subscribers that drop the oldest events only care about the latest state, so for them,
+		// dropping events is normal.
+		log := b.logger.Warnw
+		if sub.policy == DropOldest {
+			log = b.logger.Debugw
+		}
+
+		log("subscriber is full, event dropped",
+			"subscriber", sub.name,
+			"policy", sub.policy.String(),
+			"event_id", dropped.ID,
+			"type", dropped.Type,
+			"dropped", sub.Dropped(),
+		)
+	}
+}
+
+// Close closes every subscription, and stops any more events from being published.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.closed = true
+
+	for sub := range b.subs {
+		close(sub.ch)
+		delete(b.subs, sub)
+	}
+}
+
+// unsubscribe removes the given subscription from this bus, closing it's channel.
+func (b *Bus) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+
+	close(sub.ch)
+	delete(b.subs, sub)
+}
+
+// Subscription receives events published to a bus.
+type Subscription struct {
+	bus    *Bus
+	name   string
+	policy DropPolicy
+	types  map[Type]bool
+	ch     chan Event
+
+	mu      sync.Mutex
+	keep    func(Event) bool
+	dropped int
+}
+
+// Events returns the channel that this subscription's events are received from. It's closed when
+// the subscription, or the bus, is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns the number of events that have been dropped because this subscription's buffer
+// was full.
+func (s *Subscription) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dropped
+}
+
+// Keep sets a function that picks out events too important to drop. When this subscription is
+// full, the oldest event it doesn't pick out is dropped instead, whatever the drop policy, and the
+// events it picks out are only dropped if every buffered event is one of them. It returns this
+// subscription, and must be called before anything is published to it.
+func (s *Subscription) Keep(keep func(Event) bool) *Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keep = keep
+
+	return s
+}
+
+// Close stops this subscription from receiving any more events, and closes it's channel.
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+}
+
+// wants returns true if this subscription should receive the given event.
+func (s *Subscription) wants(evt Event) bool {
+	return s.types == nil || s.types[evt.Type]
+}
+
+// deliver buffers the given event, according to this subscription's drop policy. If an event had
+// to be dropped, it's returned, along with false. The bus must be read locked.
+func (s *Subscription) deliver(evt Event) (Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case s.ch <- evt:
+		return Event{}, true
+	default:
+	}
+
+	if s.keep != nil {
+		return s.deliverKeeping(evt)
+	}
+
+	if s.policy == DropNewest {
+		s.dropped++
+		return evt, false
+	}
+
+	// Only publishers take the subscription's lock, so even if the receiver takes the oldest event
+	// first, there's room for the new one either way.
+	select {
+	case oldest := <-s.ch:
+		s.ch <- evt
+		s.dropped++
+		return oldest, false
+	default:
+		s.ch <- evt
+		return Event{}, true
+	}
+}
+
+// deliverKeeping buffers the given event in a full subscription, making room by dropping an event
+// that this subscription's keep function doesn't pick out, if there is one. If an event had to be
+// dropped, it's returned, along with false. The subscription must be locked.
+func (s *Subscription) deliverKeeping(evt Event) (Event, bool) {
+	isKept := s.keep(evt)
+	if !isKept && s.policy == DropNewest {
+		s.dropped++
+		return evt, false
+	}
+
+	// The buffered events are taken out, so that one from the middle can be dropped, and then put
+	// back in the same order. Only publishers take the subscription's lock, so the receiver can
+	// only make more room in the meantime.
+	var buffered []Event
+
+drain:
+	for {
+		select {
+		case old := <-s.ch:
+			buffered = append(buffered, old)
+		default:
+			break drain
+		}
+	}
+
+	if len(buffered) < cap(s.ch) {
+		for _, old := range buffered {
+			s.ch <- old
+		}
+
+		s.ch <- evt
+
+		return Event{}, true
+	}
+
+	victim := -1
+	for i, old := range buffered {
+		if !s.keep(old) {
+			victim = i
+			break
+		}
+	}
+
+	var dropped Event
+	switch {
+	case victim >= 0:
+		dropped = buffered[victim]
+		buffered = append(buffered[:victim], buffered[victim+1:]...)
+		buffered = append(buffered, evt)
+	case !isKept:
+		dropped = evt
+	default:
+		dropped = buffered[0]
+		buffered = append(buffered[1:], evt)
+	}
+
+	for _, old := range buffered {
+		s.ch <- old
+	}
+
+	s.dropped++
+
+	return dropped, false
+}
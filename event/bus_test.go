@@ -0,0 +1,151 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/seeruk/i3adc/logging/noop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBus(t *testing.T) {
+	hotplug := New(SourceI3, ReasonHotplug)
+	applied := NewResult(TypeLayoutApplied, "abc", hotplug)
+
+	t.Run("should pass events on to every subscriber", func(t *testing.T) {
+		bus := NewBus(noop.NewLogger())
+		first := bus.Subscribe("first", 1, DropOldest)
+		second := bus.Subscribe("second", 1, DropOldest)
+
+		bus.Publish(hotplug)
+
+		assert.Equal(t, []Event{hotplug}, receive(first))
+		assert.Equal(t, []Event{hotplug}, receive(second))
+	})
+
+	t.Run("should filter events by type", func(t *testing.T) {
+		bus := NewBus(noop.NewLogger())
+		inputs := bus.Subscribe("inputs", 2, DropOldest, TypeInput)
+		results := bus.Subscribe("results", 2, DropOldest, TypeLayoutApplied, TypeLayoutFailed)
+
+		bus.Publish(hotplug)
+		bus.Publish(applied)
+
+		assert.Equal(t, []Event{hotplug}, receive(inputs))
+		assert.Equal(t, []Event{applied}, receive(results))
+	})
+
+	t.Run("should drop the oldest events when full", func(t *testing.T) {
+		bus := NewBus(noop.NewLogger())
+		sub := bus.Subscribe("slow", 2, DropOldest)
+
+		events := []Event{New(SourceI3, ReasonHotplug), New(SourceI3, ReasonHotplug), hotplug}
+		for _, evt := range events {
+			bus.Publish(evt)
+		}
+
+		assert.Equal(t, events[1:], receive(sub))
+		assert.Equal(t, 1, sub.Dropped())
+	})
+
+	t.Run("should drop the newest events when full", func(t *testing.T) {
+		bus := NewBus(noop.NewLogger())
+		sub := bus.Subscribe("slow", 2, DropNewest)
+
+		events := []Event{New(SourceI3, ReasonHotplug), New(SourceI3, ReasonHotplug), hotplug}
+		for _, evt := range events {
+			bus.Publish(evt)
+		}
+
+		assert.Equal(t, events[:2], receive(sub))
+		assert.Equal(t, 1, sub.Dropped())
+	})
+
+	t.Run("should drop events that aren't kept first when full", func(t *testing.T) {
+		bus := NewBus(noop.NewLogger())
+		sub := bus.Subscribe("slow", 2, DropOldest).Keep(func(evt Event) bool {
+			return evt.Reason == ReasonResume
+		})
+
+		resume := New(SourceLogind, ReasonResume)
+		events := []Event{resume, New(SourceI3, ReasonHotplug), hotplug}
+		for _, evt := range events {
+			bus.Publish(evt)
+		}
+
+		assert.Equal(t, []Event{resume, hotplug}, receive(sub))
+		assert.Equal(t, 1, sub.Dropped())
+	})
+
+	t.Run("should drop new events that aren't kept when full of kept events", func(t *testing.T) {
+		bus := NewBus(noop.NewLogger())
+		sub := bus.Subscribe("slow", 2, DropOldest).Keep(func(evt Event) bool {
+			return evt.Reason == ReasonResume
+		})
+
+		events := []Event{New(SourceLogind, ReasonResume), New(SourceLogind, ReasonResume), hotplug}
+		for _, evt := range events {
+			bus.Publish(evt)
+		}
+
+		assert.Equal(t, events[:2], receive(sub))
+		assert.Equal(t, 1, sub.Dropped())
+	})
+
+	t.Run("should not let a full subscriber hold up the others", func(t *testing.T) {
+		bus := NewBus(noop.NewLogger())
+		bus.Subscribe("stuck", 1, DropNewest)
+		sub := bus.Subscribe("fast", 10, DropNewest)
+
+		for i := 0; i < 5; i++ {
+			bus.Publish(hotplug)
+		}
+
+		assert.Len(t, receive(sub), 5)
+	})
+
+	t.Run("should close the channel when unsubscribed", func(t *testing.T) {
+		bus := NewBus(noop.NewLogger())
+		sub := bus.Subscribe("closed", 1, DropOldest)
+		sub.Close()
+
+		bus.Publish(hotplug)
+
+		_, ok := <-sub.Events()
+		assert.False(t, ok)
+	})
+
+	t.Run("should close every subscription when closed", func(t *testing.T) {
+		bus := NewBus(noop.NewLogger())
+		sub := bus.Subscribe("closed", 1, DropOldest)
+		bus.Close()
+
+		_, ok := <-sub.Events()
+		assert.False(t, ok)
+
+		_, ok = <-bus.Subscribe("late", 1, DropOldest).Events()
+		assert.False(t, ok)
+	})
+
+	t.Run("should drop events published to a nil bus", func(t *testing.T) {
+		var bus *Bus
+		assert.NotPanics(t, func() { bus.Publish(hotplug) })
+	})
+}
+
+// receive returns the events currently buffered by the given subscription.
+func receive(sub *Subscription) []Event {
+	var events []Event
+
+	for {
+		select {
+		case evt, ok := <-sub.Events():
+			if !ok {
+				return events
+			}
+
+			events = append(events, evt)
+		default:
+			return events
+		}
+	}
+}
@@ -1,7 +1,151 @@
 package event
 
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Type is the type of an event, which subscribers to the bus can choose to receive.
+type Type string
+
+// Possible event types.
+const (
+	// TypeInput is an event that the connected outputs may need to be looked at again for.
+	TypeInput Type = "input"
+	// TypeLayoutApplied is published after a layout has been applied.
+	TypeLayoutApplied Type = "layout-applied"
+	// TypeLayoutSaved is published after a layout has been saved.
+	TypeLayoutSaved Type = "layout-saved"
+	// TypeLayoutFailed is published when applying a layout fails.
+	TypeLayoutFailed Type = "layout-failed"
+)
+
+// Source identifies where an event came from.
+type Source string
+
+// Possible event sources.
+const (
+	// SourceDaemon is the daemon itself, e.g. when it starts.
+	SourceDaemon Source = "daemon"
+	// SourceI3 is i3's IPC.
+	SourceI3 Source = "i3"
+	// SourceControl is the control socket, i.e. an i3adc command.
+	SourceControl Source = "control"
+	// SourceSignal is a signal sent to the daemon.
+	SourceSignal Source = "signal"
+	// SourceLogind is logind, e.g. when the machine resumes from sleep.
+	SourceLogind Source = "logind"
+	// SourceUdev is the kernel's device events.
+	SourceUdev Source = "udev"
+	// SourcePoll is the polling thread, which reads the outputs at an interval.
+	SourcePoll Source = "poll"
+)
+
+// Reason describes why an event happened, and so how it should be handled.
+type Reason string
+
+// Possible event reasons.
+const (
+	// ReasonStartup is used when the daemon starts.
+	ReasonStartup Reason = "startup"
+	// ReasonHotplug is used when outputs may have been connected or disconnected.
+	ReasonHotplug Reason = "hotplug"
+	// ReasonManualChange is used when the configuration of the outputs may have been changed by
+	// the user, e.g. with xrandr.
+	ReasonManualChange Reason = "manual-change"
+	// ReasonUserRequest is used when the user has asked for the outputs to be re-evaluated, or for
+	// an action to be taken.
+	ReasonUserRequest Reason = "user-request"
+	// ReasonResume is used when the machine resumes from sleep.
+	ReasonResume Reason = "resume"
+	// ReasonReconnect is used when an event source reconnects, and may have missed events.
+	ReasonReconnect Reason = "reconnect"
+)
+
+// ActionType is the type of an action requested by an event.
+type ActionType string
+
+// Possible action types.
+const (
+	// ActionApply applies a saved layout, or profile.
+	ActionApply ActionType = "apply"
+)
+
+// Action is something specific that an event asks to be done, instead of the outputs just being
+// re-evaluated.
+type Action struct {
+	Type ActionType `json:"type"`
+	// Ref refers to the layout, or profile, that the action is for.
+	Ref string `json:"ref,omitempty"`
+}
+
 // Event represents an i3adc event.
 type Event struct {
-	// IsStartup is used to distinguish between the startup event, and regular events.
-	IsStartup bool
+	// ID identifies the event, so that everything that happens because of it can be correlated.
+	ID string `json:"id"`
+	// Type is the type of the event.
+	Type Type `json:"type"`
+	// Time is when the event happened.
+	Time time.Time `json:"time"`
+	// Source is where the event came from.
+	Source Source `json:"source"`
+	// Reason is why the event happened.
+	Reason Reason `json:"reason"`
+	// Action is an action that the event asks to be done, if any.
+	Action *Action `json:"action,omitempty"`
+
+	// CauseID is the ID of the input event that a result event was caused by.
+	CauseID string `json:"cause_id,omitempty"`
+	// Hash is the hash of the layout that a result event is about.
+	Hash string `json:"hash,omitempty"`
+	// Error describes why something failed, for failure events.
+	Error string `json:"error,omitempty"`
+}
+
+// New returns a new input event from the given source, for the given reason, with a new ID,
+// happening now.
+func New(source Source, reason Reason) Event {
+	return Event{
+		ID:     newID(),
+		Type:   TypeInput,
+		Time:   time.Now(),
+		Source: source,
+		Reason: reason,
+	}
+}
+
+// NewResult returns a new result event of the given type, about the layout with the given hash,
+// caused by the given input event. It has the same source and reason as the event that caused it.
+func NewResult(typ Type, hash string, cause Event) Event {
+	return Event{
+		ID:      newID(),
+		Type:    typ,
+		Time:    time.Now(),
+		Source:  cause.Source,
+		Reason:  cause.Reason,
+		CauseID: cause.ID,
+		Hash:    hash,
+	}
+}
+
+// WithAction returns a copy of this event that asks for the given action to be done.
+func (e Event) WithAction(action Action) Event {
+	e.Action = &action
+	return e
+}
+
+// newID returns a new random event ID.
+func newID() string {
+	bs := make([]byte, 8)
+
+	// If there's no randomness to be had, the ID is only used to correlate logs, so the time will
+	// do instead.
+	_, err := rand.Read(bs)
+	if err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+
+	return hex.EncodeToString(bs)
 }
@@ -0,0 +1,49 @@
+// Package hook runs the user's executables when things happen to layouts, e.g. so that a status
+// bar can be restarted after the primary output changes.
+package hook
+
+import (
+	"strings"
+)
+
+// Event describes something that happened to a layout, that hooks are run for. Hooks receive the
+// whole event as JSON on stdin, and the simpler fields as environment variables too.
+type Event struct {
+	// Name is the name of the event, like "post-apply". The available events are config.HookEvents.
+	Name string `json:"event"`
+	// Hash is the hash of the layout the event is about.
+	Hash string `json:"hash"`
+	// PreviousHash is the hash of the layout that was active before, if any.
+	PreviousHash string `json:"previous_hash"`
+	// Profile is the name of the profile being activated, if any.
+	Profile string `json:"profile"`
+	// Primary is the name of the primary output in the new layout.
+	Primary string `json:"primary"`
+	// Outputs contains the names of the enabled outputs in the new layout.
+	Outputs []string `json:"outputs"`
+	// Old is the layout before the event.
+	Old interface{} `json:"old"`
+	// New is the layout after the event.
+	New interface{} `json:"new"`
+	// EventID is the ID of the i3adc event that caused this, so that it can be found in the logs.
+	EventID string `json:"event_id"`
+	// Source is where the i3adc event that caused this came from, like "i3".
+	Source string `json:"source"`
+	// Reason is why the i3adc event that caused this happened, like "hotplug".
+	Reason string `json:"reason"`
+}
+
+// environ returns this event as environment variables, in the form "key=value".
+func (e Event) environ() []string {
+	return []string{
+		"I3ADC_EVENT=" + e.Name,
+		"I3ADC_HASH=" + e.Hash,
+		"I3ADC_PREVIOUS_HASH=" + e.PreviousHash,
+		"I3ADC_PROFILE=" + e.Profile,
+		"I3ADC_PRIMARY=" + e.Primary,
+		"I3ADC_OUTPUTS=" + strings.Join(e.Outputs, ","),
+		"I3ADC_EVENT_ID=" + e.EventID,
+		"I3ADC_EVENT_SOURCE=" + e.Source,
+		"I3ADC_EVENT_REASON=" + e.Reason,
+	}
+}
@@ -0,0 +1,282 @@
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/seeruk/i3adc/config"
+	"github.com/seeruk/i3adc/logging"
+)
+
+// queueSize is the number of events that may be waiting for their hooks to be run in the
+// background. If more events than this are waiting, new ones are dropped.
+const queueSize = 16
+
+// killGrace is how long to wait for a hook to exit after it has been killed.
+const killGrace = time.Second
+
+// errNotExited is used when a hook doesn't exit, even after being killed.
+var errNotExited = errors.New("hook did not exit after being killed")
+
+// Runner runs the hooks configured for each event. Hooks never affect what i3adc does; if they
+// fail, or take too long, that's logged and i3adc carries on. A nil Runner runs no hooks.
+type Runner struct {
+	logger logging.Logger
+
+	mu     sync.RWMutex
+	config config.Hooks
+
+	once  sync.Once
+	queue chan Event
+	wg    sync.WaitGroup
+}
+
+// NewRunner returns a new hook runner instance.
+func NewRunner(config config.Hooks, logger logging.Logger) *Runner {
+	logger = logger.With("module", "hook/runner")
+
+	return &Runner{
+		config: config,
+		logger: logger,
+		queue:  make(chan Event, queueSize),
+	}
+}
+
+// Run runs the hooks for the given event, one at a time, waiting for them to finish. It's used for
+// pre-apply hooks, which the layout waits for, so all of the hooks together can take at most the
+// configured pre-apply timeout, as well as each taking at most the configured timeout. Any hooks
+// left when that runs out are skipped.
+func (r *Runner) Run(evt Event) {
+	if r == nil {
+		return
+	}
+
+	r.wg.Add(1)
+	defer r.wg.Done()
+
+	r.mu.RLock()
+	budget := time.Duration(r.config.PreApplyTimeout)
+	r.mu.RUnlock()
+
+	r.run(evt, budget)
+}
+
+// Start queues the hooks for the given event to be run in the background, in the order events are
+// started, without waiting for them. If too many events are already queued, the event is dropped.
+func (r *Runner) Start(evt Event) {
+	if r == nil {
+		return
+	}
+
+	r.once.Do(func() {
+		go func() {
+			for evt := range r.queue {
+				r.run(evt, 0)
+				r.wg.Done()
+			}
+		}()
+	})
+
+	r.wg.Add(1)
+
+	select {
+	case r.queue <- evt:
+	default:
+		r.wg.Done()
+		r.logger.Warnw("too many hooks waiting to run, skipping event", "event", evt.Name)
+	}
+}
+
+// SetConfig replaces the runner's configuration. Hooks that are already running carry on with the
+// configuration they were started with.
+func (r *Runner) SetConfig(config config.Hooks) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.config = config
+}
+
+// Wait waits for any hooks that are running or queued to finish.
+func (r *Runner) Wait() {
+	if r == nil {
+		return
+	}
+
+	r.wg.Wait()
+}
+
+// run runs each of the hooks for the given event, in turn. If the given budget isn't 0, the hooks
+// can take at most that long all together.
+func (r *Runner) run(evt Event, budget time.Duration) {
+	// Hooks are the user's code, but a mistake here shouldn't take i3adc down with them either.
+	defer func() {
+		if rec := recover(); rec != nil {
+			r.logger.Errorw("panic running hooks", "event", evt.Name, "panic", rec)
+		}
+	}()
+
+	r.mu.RLock()
+	config := r.config
+	r.mu.RUnlock()
+
+	hooks := r.hooks(config, evt.Name)
+	if len(hooks) == 0 {
+		return
+	}
+
+	stdin, err := json.Marshal(evt)
+	if err != nil {
+		r.logger.Errorw("failed to encode hook event", "event", evt.Name, "error", err)
+		return
+	}
+
+	deadline := time.Now().Add(budget)
+
+	for i, hook := range hooks {
+		timeout := time.Duration(config.Timeout)
+
+		if budget > 0 {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				r.logger.Warnw("out of time for hooks, skipping the rest",
+					"event", evt.Name,
+					"event_id", evt.EventID,
+					"budget", budget,
+					"skipped", len(hooks)-i,
+				)
+
+				return
+			}
+
+			if remaining < timeout {
+				timeout = remaining
+			}
+		}
+
+		r.runHook(hook, evt, stdin, timeout)
+	}
+}
+
+// runHook runs a single hook, logging it's output, and killing it if it takes longer than the given
+// timeout.
+func (r *Runner) runHook(cmd *exec.Cmd, evt Event, stdin []byte, timeout time.Duration) {
+	logger := r.logger.With("event", evt.Name, "event_id", evt.EventID, "hook", hookName(cmd))
+
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), evt.environ()...)
+
+	// Run the hook in it's own process group, so that anything it starts can be killed with it.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	logger.Debug("running hook")
+
+	start := time.Now()
+
+	err := cmd.Start()
+	if err != nil {
+		logger.Errorw("failed to start hook", "error", err)
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var timedOut bool
+
+	select {
+	case err = <-done:
+	case <-timer.C:
+		timedOut = true
+
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+
+		select {
+		case err = <-done:
+		case <-time.After(killGrace):
+			// The output can't be read safely while the hook may still be writing it.
+			logger.Errorw("hook timed out", "timeout", timeout, "error", errNotExited)
+			return
+		}
+	}
+
+	logOutput(logger.Infow, stdout.Bytes())
+	logOutput(logger.Warnw, stderr.Bytes())
+
+	switch {
+	case timedOut:
+		logger.Errorw("hook timed out", "timeout", timeout)
+	case err != nil:
+		logger.Errorw("hook failed", "error", err, "duration", time.Since(start))
+	default:
+		logger.Debugw("hook finished", "duration", time.Since(start))
+	}
+}
+
+// hooks returns the commands to run for the event with the given name, using the given
+// configuration. Configured commands are run first, in order, followed by the executables in the
+// event's hook directory, sorted by name.
+func (r *Runner) hooks(config config.Hooks, name string) []*exec.Cmd {
+	var hooks []*exec.Cmd
+	for _, command := range config.Commands[name] {
+		hooks = append(hooks, exec.Command("sh", "-c", command))
+	}
+
+	if config.Directory == "" {
+		return hooks
+	}
+
+	dir := filepath.Join(config.Directory, name)
+
+	// ReadDir sorts by name already.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		r.logger.Errorw("failed to read hook directory", "directory", dir, "error", err)
+	}
+
+	for _, file := range files {
+		if file.Mode().IsRegular() && file.Mode()&0111 != 0 {
+			hooks = append(hooks, exec.Command(filepath.Join(dir, file.Name())))
+		}
+	}
+
+	return hooks
+}
+
+// hookName returns a name for the given hook command, to use in logs.
+func hookName(cmd *exec.Cmd) string {
+	if len(cmd.Args) == 3 && cmd.Args[0] == "sh" && cmd.Args[1] == "-c" {
+		return cmd.Args[2]
+	}
+
+	return cmd.Path
+}
+
+// logOutput logs each line of the given output from a hook, using the given log function.
+func logOutput(log func(msg string, args ...interface{}), output []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		log("hook output", "line", scanner.Text())
+	}
+}
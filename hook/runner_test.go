@@ -0,0 +1,133 @@
+package hook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seeruk/i3adc/config"
+	"github.com/seeruk/i3adc/logging/noop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i3adc-hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	evt := Event{
+		Name:    config.HookPostApply,
+		Hash:    "abc",
+		Primary: "DP-1",
+		Outputs: []string{"eDP-1", "DP-1"},
+		New:     []string{"layout"},
+	}
+
+	t.Run("should pass the event on stdin and in the environment", func(t *testing.T) {
+		out := filepath.Join(dir, "env")
+
+		runner := NewRunner(config.Hooks{
+			Timeout: config.Duration(5 * time.Second),
+			Commands: map[string][]string{
+				config.HookPostApply: {`cat > "$OUT.json"; echo "$I3ADC_EVENT $I3ADC_HASH $I3ADC_PRIMARY $I3ADC_OUTPUTS" > "$OUT"`},
+			},
+		}, noop.NewLogger())
+
+		os.Setenv("OUT", out)
+		defer os.Unsetenv("OUT")
+
+		runner.Run(evt)
+
+		env, err := ioutil.ReadFile(out)
+		assert.NoError(t, err)
+		assert.Equal(t, "post-apply abc DP-1 eDP-1,DP-1\n", string(env))
+
+		stdin, err := ioutil.ReadFile(out + ".json")
+		assert.NoError(t, err)
+
+		var decoded Event
+		assert.NoError(t, json.Unmarshal(stdin, &decoded))
+		assert.Equal(t, evt.Hash, decoded.Hash)
+		assert.Equal(t, []interface{}{"layout"}, decoded.New)
+	})
+
+	t.Run("should run executables in the event's directory, after commands", func(t *testing.T) {
+		out := filepath.Join(dir, "order")
+		hookDir := filepath.Join(dir, "hooks", config.HookPostApply)
+
+		assert.NoError(t, os.MkdirAll(hookDir, 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(hookDir, "b"), []byte("#!/bin/sh\necho b >> "+out+"\n"), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(hookDir, "a"), []byte("#!/bin/sh\necho a >> "+out+"\n"), 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(hookDir, "README"), []byte("not a hook"), 0600))
+
+		runner := NewRunner(config.Hooks{
+			Directory: filepath.Join(dir, "hooks"),
+			Timeout:   config.Duration(5 * time.Second),
+			Commands: map[string][]string{
+				config.HookPostApply: {"echo command >> " + out},
+			},
+		}, noop.NewLogger())
+
+		runner.Start(evt)
+		runner.Wait()
+
+		order, err := ioutil.ReadFile(out)
+		assert.NoError(t, err)
+		assert.Equal(t, "command\na\nb\n", string(order))
+	})
+
+	t.Run("should kill hooks that take too long, and carry on with the rest", func(t *testing.T) {
+		out := filepath.Join(dir, "after-timeout")
+
+		runner := NewRunner(config.Hooks{
+			Timeout: config.Duration(100 * time.Millisecond),
+			Commands: map[string][]string{
+				config.HookPostApply: {"sleep 10 & sleep 10", "exit 1", "touch " + out},
+			},
+		}, noop.NewLogger())
+
+		start := time.Now()
+		runner.Run(evt)
+
+		assert.True(t, time.Since(start) < 5*time.Second, "expected hook to be killed")
+		assert.FileExists(t, out)
+	})
+
+	t.Run("should limit how long all of the hooks take together when waiting", func(t *testing.T) {
+		out := filepath.Join(dir, "after-budget")
+
+		runner := NewRunner(config.Hooks{
+			Timeout:         config.Duration(5 * time.Second),
+			PreApplyTimeout: config.Duration(100 * time.Millisecond),
+			Commands: map[string][]string{
+				config.HookPreApply: {"sleep 10", "touch " + out},
+			},
+		}, noop.NewLogger())
+
+		start := time.Now()
+		runner.Run(Event{Name: config.HookPreApply})
+
+		assert.True(t, time.Since(start) < 5*time.Second, "expected hook to be killed")
+		assert.False(t, fileExists(out), "expected remaining hooks to be skipped")
+	})
+
+	t.Run("should do nothing if nil", func(t *testing.T) {
+		var runner *Runner
+
+		runner.Run(evt)
+		runner.Start(evt)
+		runner.Wait()
+	})
+}
+
+// fileExists returns true if a file exists at the given path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,78 @@
+package i3
+
+import (
+	"fmt"
+
+	"github.com/seeruk/i3adc/logging"
+	"go.i3wm.org/i3"
+)
+
+// Client talks to i3 over it's IPC, to read and move workspaces, and to run commands. If i3 can't
+// be reached (e.g. i3adc is running under another window manager), it's methods do nothing. A nil
+// Client does nothing either.
+type Client struct {
+	logger logging.Logger
+}
+
+// NewClient returns a new i3 client instance.
+func NewClient(logger logging.Logger) *Client {
+	logger = logger.With("module", "i3/client")
+
+	return &Client{
+		logger: logger,
+	}
+}
+
+// RunCommands runs each of the given i3 commands in turn. A command that fails is logged, and
+// doesn't stop the rest from running. The number of commands that failed is returned.
+func (c *Client) RunCommands(commands []string) int {
+	if c == nil || len(commands) == 0 {
+		return 0
+	}
+
+	if !c.isAvailable() {
+		c.logger.Warnw("i3 is not available, skipping i3 commands", "commands", len(commands))
+		return len(commands)
+	}
+
+	var failed int
+	for _, command := range commands {
+		err := c.runCommand(command)
+		if err != nil {
+			c.logger.Errorw("i3 command failed", "command", command, "error", err)
+			failed++
+			continue
+		}
+
+		c.logger.Debugw("ran i3 command", "command", command)
+	}
+
+	return failed
+}
+
+// isAvailable returns true if i3 can be reached over it's IPC.
+func (c *Client) isAvailable() bool {
+	_, err := i3.GetVersion()
+	if err != nil {
+		c.logger.Debugw("i3 is not available", "error", err)
+		return false
+	}
+
+	return true
+}
+
+// runCommand runs the given i3 command, returning an error if any part of it failed.
+func (c *Client) runCommand(command string) error {
+	results, err := i3.RunCommand(command)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if !result.Success {
+			return fmt.Errorf("i3: command failed: %s", result.Error)
+		}
+	}
+
+	return nil
+}
@@ -2,66 +2,77 @@ package i3
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/seeruk/i3adc/event"
 	"github.com/seeruk/i3adc/logging"
 	"go.i3wm.org/i3"
 )
 
-// Thread is a background thread designed to push output events from the i3 IPC into a channel to
-// trigger other functionality in i3adc.
+const (
+	// minBackoff is how long the thread first waits before trying to reconnect to i3.
+	minBackoff = 100 * time.Millisecond
+	// maxBackoff is the longest the thread will wait between attempts to reconnect to i3.
+	maxBackoff = 5 * time.Second
+)
+
+// Thread is a background thread designed to publish output events from the i3 IPC to the event bus,
+// to trigger other functionality in i3adc. If i3 restarts, the thread reconnects to it, and if i3
+// exits, the thread stops on it's own.
 type Thread struct {
 	ctx    context.Context
 	cfn    context.CancelFunc
 	logger logging.Logger
-	msgCh  chan<- event.Event
-	rcvr   *i3.EventReceiver
+	bus    *event.Bus
+
+	mu   sync.Mutex
+	rcvr *i3.EventReceiver
 }
 
-// NewThread creates a new output event thread instance.
-func NewThread(logger logging.Logger) (*Thread, <-chan event.Event) {
+// NewThread creates a new output event thread instance, that will publish events to the given bus.
+func NewThread(logger logging.Logger, bus *event.Bus) *Thread {
 	logger = logger.With("module", "i3/thread")
-	msgCh := make(chan event.Event, 1)
 
 	return &Thread{
 		logger: logger,
-		msgCh:  msgCh,
-	}, msgCh
+		bus:    bus,
+	}
 }
 
-// Start begins waiting for events from i3, pushing them onto the message channel when possible.
+// Start begins waiting for events from i3, publishing them to the bus. It returns when the thread is
+// stopped, or when i3 exits.
 func (t *Thread) Start() error {
 	t.logger.Info("thread started")
-	t.msgCh <- event.Event{IsStartup: true} // Send initial message at startup.
 
+	t.mu.Lock()
 	t.ctx, t.cfn = context.WithCancel(context.Background())
+	t.mu.Unlock()
+
+	backoff := minBackoff
+	isReconnect := false
 
-	t.rcvr = i3.Subscribe(i3.OutputEventType)
-	defer t.rcvr.Close()
-
-	// TODO(seeruk): Can something be done here to avoid processing loads of events that come though
-	// in quick succession?
-
-	// Use a goroutine to allow this thread to be stopped. This goroutine will not die though, which
-	// is very unfortunate, but shouldn't be a problem for i3adc, given it's current implementation.
-	go func() {
-		for t.rcvr.Next() {
-			// Check context here so that we break out of the loop if possible. It may not always be
-			// the case, meaning sometimes we may have a routine being leaked, at least for 5
-			// seconds. This should only really happen when we're quitting anyway.
-			select {
-			case <-t.ctx.Done():
+	for {
+		if isReconnect {
+			if !t.waitForI3(&backoff) {
 				break
-			default:
 			}
 
-			t.logger.Debugw("received event from i3", "event", t.rcvr.Event())
-			t.msgCh <- event.Event{}
+			// Whatever happened while we weren't listening has been missed, so the outputs need to
+			// be looked at again.
+			t.logger.Info("reconnected to i3")
+			t.send(event.New(event.SourceI3, event.ReasonReconnect))
+		}
+
+		isExit, err := t.receive()
+		if isExit || t.ctx.Err() != nil {
+			break
 		}
-	}()
 
-	// Wait for stop signal.
-	<-t.ctx.Done()
+		t.logger.Warnw("lost connection to i3, reconnecting", "error", err)
+
+		isReconnect = true
+	}
 
 	t.logger.Info("thread stopped")
 
@@ -72,9 +83,86 @@ func (t *Thread) Start() error {
 func (t *Thread) Stop() error {
 	t.logger.Infow("thread stopping")
 
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	if t.ctx != nil && t.cfn != nil {
 		t.cfn()
 	}
 
+	// Closing the receiver is the only way to interrupt a call to Next.
+	if t.rcvr != nil {
+		t.rcvr.Close()
+	}
+
 	return nil
 }
+
+// receive subscribes to i3's events, and passes output events on until the subscription ends. If it
+// ended because i3 is exiting, true is returned.
+func (t *Thread) receive() (bool, error) {
+	t.mu.Lock()
+	if t.ctx.Err() != nil {
+		t.mu.Unlock()
+		return false, nil
+	}
+
+	rcvr := i3.Subscribe(i3.OutputEventType, i3.ShutdownEventType)
+	t.rcvr = rcvr
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		t.rcvr = nil
+		t.mu.Unlock()
+
+		rcvr.Close()
+	}()
+
+	for rcvr.Next() {
+		switch evt := rcvr.Event().(type) {
+		case *i3.ShutdownEvent:
+			t.logger.Infow("i3 is shutting down", "change", evt.Change)
+
+			// When i3 restarts, the connection will drop, and we'll reconnect once it's back.
+			if evt.Change == "exit" {
+				return true, nil
+			}
+		default:
+			t.logger.Debugw("received event from i3", "event", evt)
+			t.send(event.New(event.SourceI3, event.ReasonHotplug))
+		}
+	}
+
+	return false, rcvr.Err()
+}
+
+// waitForI3 waits until i3 can be reached, backing off between attempts. It returns false if the
+// thread is stopped while waiting.
+func (t *Thread) waitForI3(backoff *time.Duration) bool {
+	for {
+		select {
+		case <-t.ctx.Done():
+			return false
+		case <-time.After(*backoff):
+		}
+
+		_, err := i3.GetVersion()
+		if err == nil {
+			*backoff = minBackoff
+			return true
+		}
+
+		t.logger.Debugw("i3 is not available yet", "error", err, "backoff", backoff.String())
+
+		*backoff *= 2
+		if *backoff > maxBackoff {
+			*backoff = maxBackoff
+		}
+	}
+}
+
+// send publishes the given event to the bus.
+func (t *Thread) send(evt event.Event) {
+	t.bus.Publish(evt)
+}
@@ -0,0 +1,167 @@
+package i3
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.i3wm.org/i3"
+)
+
+// Settings for waiting for i3 to notice outputs that have just been enabled.
+const (
+	outputWaitInterval = 50 * time.Millisecond
+	outputWaitTimeout  = 2 * time.Second
+)
+
+// Workspace records which output an i3 workspace is on.
+type Workspace struct {
+	Name      string `json:"name"`
+	Output    string `json:"output"`
+	IsVisible bool   `json:"is_visible"`
+	IsFocused bool   `json:"is_focused"`
+}
+
+// Workspaces returns every workspace, and the output it's on. If i3 isn't available, there are no
+// workspaces.
+func (c *Client) Workspaces() ([]Workspace, error) {
+	if c == nil || !c.isAvailable() {
+		return nil, nil
+	}
+
+	i3Workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		return nil, fmt.Errorf("i3: failed to get workspaces: %v", err)
+	}
+
+	workspaces := make([]Workspace, 0, len(i3Workspaces))
+	for _, workspace := range i3Workspaces {
+		workspaces = append(workspaces, Workspace{
+			Name:      workspace.Name,
+			Output:    workspace.Output,
+			IsVisible: workspace.Visible,
+			IsFocused: workspace.Focused,
+		})
+	}
+
+	return workspaces, nil
+}
+
+// Restore moves the given workspaces back to the outputs they were on, if those workspaces still
+// exist, and those outputs are active. Workspaces that were visible are made visible again, and the
+// workspace that was focused is focused again. If a workspace can't be moved, the rest still are.
+// If i3 isn't available, nothing is moved.
+func (c *Client) Restore(workspaces []Workspace) error {
+	if c == nil || len(workspaces) == 0 || !c.isAvailable() {
+		return nil
+	}
+
+	// i3 finds out about output changes after they've happened, so they may not be ready yet.
+	outputs, err := c.waitForOutputs(workspaces)
+	if err != nil {
+		return err
+	}
+
+	current, err := c.Workspaces()
+	if err != nil {
+		return err
+	}
+
+	currentOutputs := make(map[string]string, len(current))
+	for _, workspace := range current {
+		currentOutputs[workspace.Name] = workspace.Output
+	}
+
+	var moved int
+	var focused string
+
+	for _, workspace := range workspaces {
+		output, ok := currentOutputs[workspace.Name]
+		if !ok || output == workspace.Output || !outputs[workspace.Output] {
+			continue
+		}
+
+		c.logger.Debugw("moving workspace", "workspace", workspace.Name, "from", output, "to", workspace.Output)
+
+		err := c.runCommand(fmt.Sprintf("workspace --no-auto-back-and-forth %s; move workspace to output %s",
+			quote(workspace.Name),
+			quote(workspace.Output),
+		))
+
+		if err != nil {
+			c.logger.Warnw("failed to move workspace", "workspace", workspace.Name, "output", workspace.Output, "error", err)
+			continue
+		}
+
+		moved++
+	}
+
+	if moved == 0 {
+		return nil
+	}
+
+	// Moving workspaces means focusing them, so the ones that were visible need showing again. The
+	// focused one is shown last, so that it keeps focus.
+	for _, workspace := range workspaces {
+		if workspace.IsFocused {
+			focused = workspace.Name
+			continue
+		}
+
+		if _, ok := currentOutputs[workspace.Name]; ok && workspace.IsVisible {
+			c.showWorkspace(workspace.Name)
+		}
+	}
+
+	if _, ok := currentOutputs[focused]; ok {
+		c.showWorkspace(focused)
+	}
+
+	c.logger.Infow("restored workspaces", "moved", moved)
+
+	return nil
+}
+
+// showWorkspace switches to the workspace with the given name, logging any error.
+func (c *Client) showWorkspace(name string) {
+	err := c.runCommand("workspace --no-auto-back-and-forth " + quote(name))
+	if err != nil {
+		c.logger.Warnw("failed to show workspace", "workspace", name, "error", err)
+	}
+}
+
+// waitForOutputs waits until i3 reports that the outputs the given workspaces are on are active, or
+// until it's waited long enough. The outputs that i3 reports as active are returned.
+func (c *Client) waitForOutputs(workspaces []Workspace) (map[string]bool, error) {
+	deadline := time.Now().Add(outputWaitTimeout)
+
+	for {
+		i3Outputs, err := i3.GetOutputs()
+		if err != nil {
+			return nil, fmt.Errorf("i3: failed to get outputs: %v", err)
+		}
+
+		outputs := make(map[string]bool, len(i3Outputs))
+		for _, output := range i3Outputs {
+			outputs[output.Name] = output.Active
+		}
+
+		ready := true
+		for _, workspace := range workspaces {
+			if _, ok := outputs[workspace.Output]; ok && !outputs[workspace.Output] {
+				ready = false
+			}
+		}
+
+		if ready || time.Now().After(deadline) {
+			return outputs, nil
+		}
+
+		time.Sleep(outputWaitInterval)
+	}
+}
+
+// quote quotes the given string for use as an argument in an i3 command.
+func quote(str string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(str) + `"`
+}
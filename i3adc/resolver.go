@@ -2,10 +2,19 @@ package i3adc
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/seeruk/i3adc/config"
+	"github.com/seeruk/i3adc/control"
+	"github.com/seeruk/i3adc/event"
+	"github.com/seeruk/i3adc/hook"
+	"github.com/seeruk/i3adc/i3"
 	"github.com/seeruk/i3adc/logging"
 	"github.com/seeruk/i3adc/logging/zap"
+	"github.com/seeruk/i3adc/logind"
 	"github.com/seeruk/i3adc/state/bolt"
+	"github.com/seeruk/i3adc/systemd"
+	"github.com/seeruk/i3adc/udev"
 	"github.com/seeruk/i3adc/xrandr"
 
 	boltdb "github.com/coreos/bbolt"
@@ -16,25 +25,52 @@ import (
 // asking for, and it will continue to follow that path until the entire dependency tree has been
 // resolved for the dependency you're asking for.
 type Resolver struct {
-	boltDB       *boltdb.DB
-	logger       logging.Logger
-	xrandrClient *xrandr.Client
+	boltDB        *boltdb.DB
+	config        config.Config
+	eventBus      *event.Bus
+	xrandrSub     *event.Subscription
+	hookRunner    *hook.Runner
+	logger        logging.Logger
+	shutdownCh    chan struct{}
+	xrandrClient  *xrandr.Client
+	xrandrManager *xrandr.Manager
 }
 
-// NewResolver returns a new dependency resolver instance.
-func NewResolver() *Resolver {
-	resolver := &Resolver{}
+// NewResolver returns a new dependency resolver instance, that builds dependencies using the given
+// configuration.
+func NewResolver(config config.Config) *Resolver {
+	resolver := &Resolver{
+		config: config,
+	}
+
 	resolver.resolveEager()
 
 	return resolver
 }
 
+// NewCommandResolver returns a new dependency resolver instance for short-lived commands. Commands
+// log less, and log to stderr, so that logs don't get mixed up with the command's output.
+func NewCommandResolver(config config.Config) *Resolver {
+	config.Logging.Output = zap.OutputStderr
+	if config.Logging.Level == logging.DebugLevel || config.Logging.Level == logging.InfoLevel {
+		config.Logging.Level = logging.WarnLevel
+	}
+
+	return NewResolver(config)
+}
+
+// ResolveConfig resolves the application configuration.
+func (r *Resolver) ResolveConfig() config.Config {
+	return r.config
+}
+
 // ResolverLogger resolves the singleton application logger instance.
 func (r *Resolver) ResolveLogger() logging.Logger {
 	if r.logger == nil {
-		zapper := zap.New(zap.Config{
-			Level: logging.DebugLevel,
-		})
+		zapper, err := zap.New(r.config.Logging)
+		if err != nil {
+			panic(fmt.Sprintf("i3adc: failed to resolve logger: %v", err))
+		}
 
 		r.logger = zap.NewLogger(zapper.Sugar())
 	}
@@ -45,7 +81,7 @@ func (r *Resolver) ResolveLogger() logging.Logger {
 // ResolveBoltDB resolves the singleton application bolt DB instance.
 func (r *Resolver) ResolveBoltDB() *boltdb.DB {
 	if r.boltDB == nil {
-		db, err := bolt.OpenDB()
+		db, err := bolt.OpenDB(r.config.State.Path)
 		if err != nil {
 			panic(fmt.Sprintf("i3adc: failed to resolve bolt DB: %v", err))
 		}
@@ -69,7 +105,7 @@ func (r *Resolver) ResolveStateBackend() *bolt.Backend {
 // ResolveXrandrClient resolves the singleton application xrandr client instance.
 func (r *Resolver) ResolveXrandrClient() *xrandr.Client {
 	if r.xrandrClient == nil {
-		client, err := xrandr.NewClient()
+		client, err := xrandr.NewClient(xrandr.NewDRMReader(xrandr.DefaultDRMRoot), r.ResolveLogger())
 		if err != nil {
 			panic(fmt.Sprintf("i3adc: failed to resolve xrandr client: %v", err))
 		}
@@ -80,6 +116,153 @@ func (r *Resolver) ResolveXrandrClient() *xrandr.Client {
 	return r.xrandrClient
 }
 
+// ResolveXrandrStore resolves an xrandr layout store instance, creating a new instance each time.
+func (r *Resolver) ResolveXrandrStore() *xrandr.Store {
+	return xrandr.NewStore(r.ResolveStateBackend())
+}
+
+// ResolveXrandrManager resolves the singleton application xrandr layout manager instance.
+func (r *Resolver) ResolveXrandrManager() *xrandr.Manager {
+	if r.xrandrManager == nil {
+		r.xrandrManager = xrandr.NewManager(
+			r.ResolveXrandrStore(),
+			r.ResolveXrandrClient(),
+			r.ResolveI3Client(),
+			r.ResolveHookRunner(),
+			r.ResolveEventBus(),
+			r.config,
+			r.ResolveLogger(),
+		)
+	}
+
+	return r.xrandrManager
+}
+
+// ResolveHookRunner resolves the singleton application hook runner instance.
+func (r *Resolver) ResolveHookRunner() *hook.Runner {
+	if r.hookRunner == nil {
+		r.hookRunner = hook.NewRunner(r.config.Hooks, r.ResolveLogger())
+	}
+
+	return r.hookRunner
+}
+
+// ResolveXrandrThread resolves an xrandr thread instance, creating a new instance each time.
+func (r *Resolver) ResolveXrandrThread() *xrandr.Thread {
+	return xrandr.NewThread(
+		r.ResolveXrandrManager(),
+		r.ResolveLogger(),
+		r.ResolveXrandrSubscription().Events(),
+		time.Duration(r.config.Events.Settle),
+	)
+}
+
+// ResolveSystemdNotifier resolves a systemd notifier instance, creating a new instance each time.
+// If i3adc wasn't started by systemd, there's no notifier, and nil is returned.
+func (r *Resolver) ResolveSystemdNotifier() *systemd.Notifier {
+	return systemd.NewNotifier()
+}
+
+// ResolveSystemdWatchdog resolves a systemd watchdog thread instance, creating a new instance each
+// time. The given function decides whether i3adc is healthy enough to ping the watchdog. It should
+// only be used if systemd has enabled the watchdog.
+func (r *Resolver) ResolveSystemdWatchdog(isHealthy func() bool) *systemd.Watchdog {
+	interval := systemd.WatchdogInterval()
+	return systemd.NewWatchdog(r.ResolveSystemdNotifier(), interval, isHealthy, r.ResolveLogger())
+}
+
+// ResolveEventBus resolves the singleton event bus that event sources publish events to, along
+// with the results of handling them.
+func (r *Resolver) ResolveEventBus() *event.Bus {
+	if r.eventBus == nil {
+		r.eventBus = event.NewBus(r.ResolveLogger())
+	}
+
+	return r.eventBus
+}
+
+// ResolveXrandrSubscription resolves the singleton subscription that the xrandr thread receives
+// input events from. It outlives any one thread, so that events aren't missed while it restarts.
+// When it's full, plain hotplug events are dropped before any that ask for more.
+func (r *Resolver) ResolveXrandrSubscription() *event.Subscription {
+	if r.xrandrSub == nil {
+		r.xrandrSub = r.ResolveEventBus().
+			Subscribe("xrandr", 64, event.DropOldest, event.TypeInput).
+			Keep(xrandr.IsImportantEvent)
+	}
+
+	return r.xrandrSub
+}
+
+// ResolveShutdownChannel resolves the singleton channel used to ask the daemon to exit.
+func (r *Resolver) ResolveShutdownChannel() chan struct{} {
+	if r.shutdownCh == nil {
+		r.shutdownCh = make(chan struct{}, 1)
+	}
+
+	return r.shutdownCh
+}
+
+// ResolveI3Client resolves an i3 client instance, creating a new instance each time. It's needed
+// whether or not i3 is used as an event source, and copes with i3 not running on it's own.
+func (r *Resolver) ResolveI3Client() *i3.Client {
+	return i3.NewClient(r.ResolveLogger())
+}
+
+// ResolveI3Thread resolves an i3 event thread instance, creating a new instance each time.
+func (r *Resolver) ResolveI3Thread() *i3.Thread {
+	return i3.NewThread(r.ResolveLogger(), r.ResolveEventBus())
+}
+
+// ResolveLogindThread resolves a logind thread instance, creating a new instance each time. It
+// listens to the system bus.
+func (r *Resolver) ResolveLogindThread() *logind.Thread {
+	delay := time.Duration(r.config.Events.Logind.Delay)
+	return logind.NewThread("", delay, r.ResolveEventBus(), r.ResolveLogger())
+}
+
+// ResolvePollThread resolves an xrandr polling thread instance, creating a new instance each time.
+func (r *Resolver) ResolvePollThread() *xrandr.PollThread {
+	return xrandr.NewPollThread(
+		r.ResolveXrandrClient(),
+		r.ResolveEventBus(),
+		r.ResolveLogger(),
+		time.Duration(r.config.Events.Poll.Interval),
+		time.Duration(r.config.Events.Poll.MaxInterval),
+	)
+}
+
+// ResolveUdevThread resolves a udev thread instance, creating a new instance each time.
+func (r *Resolver) ResolveUdevThread() *udev.Thread {
+	return udev.NewThread(r.ResolveEventBus(), r.ResolveLogger())
+}
+
+// ResolveControlServer resolves a control socket server instance, creating a new instance each
+// time.
+func (r *Resolver) ResolveControlServer() *control.Server {
+	path, err := control.SocketPath()
+	if err != nil {
+		panic(fmt.Sprintf("i3adc: failed to resolve control socket path: %v", err))
+	}
+
+	shutdownCh := r.ResolveShutdownChannel()
+
+	// Asking more than once is the same as asking once.
+	service := control.NewService(r.ResolveXrandrManager(), func() {
+		select {
+		case shutdownCh <- struct{}{}:
+		default:
+		}
+	})
+
+	server, err := control.NewServer(path, service, r.ResolveLogger())
+	if err != nil {
+		panic(fmt.Sprintf("i3adc: failed to resolve control server: %v", err))
+	}
+
+	return server
+}
+
 // resolveEager attempts to resolve dependencies that may error, so that those errors may be
 // encountered at startup, instead of further into the application's life.
 func (r *Resolver) resolveEager() {
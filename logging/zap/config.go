@@ -2,7 +2,46 @@ package zap
 
 import "github.com/seeruk/i3adc/logging"
 
+// Output values.
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+	// OutputFile writes to a file, which is rotated once it gets too big.
+	OutputFile = "file"
+	// OutputJournal sends structured entries straight to systemd's journal.
+	OutputJournal = "journal"
+)
+
+// Format values.
+const (
+	// FormatAuto uses the console format when attached to a terminal, and JSON otherwise. If the
+	// output is stdout or stderr, and systemd has connected it to the journal, entries are sent
+	// straight to the journal instead.
+	FormatAuto = "auto"
+	// FormatConsole uses a human-friendly format.
+	FormatConsole = "console"
+	// FormatJSON uses JSON, one object per line.
+	FormatJSON = "json"
+)
+
 // Config contains all of the configuration relevant to a zap-based logger.
 type Config struct {
-	Level logging.Level `json:"level" consul:"level" env:"level"`
+	Level  logging.Level `json:"level" consul:"level" env:"level"`
+	Format string        `json:"format" consul:"format" env:"format"`
+	Output string        `json:"output" consul:"output" env:"output"`
+	File   FileConfig    `json:"file" consul:"file" env:"file"`
+	// Modules overrides the level for loggers with the given module field (e.g. "xrandr/manager").
+	// A module also covers the modules beneath it, so "xrandr" covers "xrandr/manager", unless
+	// it's more specifically overridden.
+	Modules map[string]logging.Level `json:"modules" consul:"modules" env:"modules"`
+}
+
+// FileConfig contains the configuration of the file output.
+type FileConfig struct {
+	// Path is the path of the log file.
+	Path string `json:"path" consul:"path" env:"path"`
+	// MaxSize is how big the log file can get, in megabytes, before it is rotated.
+	MaxSize int `json:"max_size" consul:"max_size" env:"max_size"`
+	// MaxBackups is how many rotated log files are kept.
+	MaxBackups int `json:"max_backups" consul:"max_backups" env:"max_backups"`
 }
@@ -0,0 +1,123 @@
+package zap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/seeruk/i3adc/systemd"
+	"go.uber.org/zap/zapcore"
+)
+
+// journalIdentifier is the SYSLOG_IDENTIFIER given to every journal entry.
+const journalIdentifier = "i3adc"
+
+// journalPriorities maps zap's levels to syslog priorities.
+var journalPriorities = map[zapcore.Level]int{
+	zapcore.DebugLevel:  systemd.PriorityDebug,
+	zapcore.InfoLevel:   systemd.PriorityInfo,
+	zapcore.WarnLevel:   systemd.PriorityWarning,
+	zapcore.ErrorLevel:  systemd.PriorityErr,
+	zapcore.DPanicLevel: systemd.PriorityCrit,
+	zapcore.PanicLevel:  systemd.PriorityCrit,
+	zapcore.FatalLevel:  systemd.PriorityCrit,
+}
+
+// journalCore is a zapcore.Core that sends each entry to the journal, with it's fields as journal
+// fields, so that they can be used to filter entries (e.g. `journalctl MODULE=xrandr/manager`).
+type journalCore struct {
+	zapcore.LevelEnabler
+
+	journal *systemd.Journal
+	fields  []zapcore.Field
+}
+
+// newJournalCore returns a new journal core, that sends entries enabled by the given level enabler
+// to the given journal.
+func newJournalCore(journal *systemd.Journal, enabler zapcore.LevelEnabler) *journalCore {
+	return &journalCore{
+		LevelEnabler: enabler,
+		journal:      journal,
+	}
+}
+
+// With returns a copy of this core, with the given fields added to every entry.
+func (c *journalCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+
+	return &clone
+}
+
+// Check adds this core to the given checked entry, if the entry's level is enabled.
+func (c *journalCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+// Write sends the given entry, and it's fields, to the journal.
+func (c *journalCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(enc)
+	}
+
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	journalFields := map[string]string{
+		"MESSAGE":           entry.Message,
+		"PRIORITY":          strconv.Itoa(journalPriorities[entry.Level]),
+		"SYSLOG_IDENTIFIER": journalIdentifier,
+	}
+
+	if entry.Caller.Defined {
+		journalFields["CODE_FILE"] = entry.Caller.File
+		journalFields["CODE_LINE"] = strconv.Itoa(entry.Caller.Line)
+	}
+
+	for key, value := range enc.Fields {
+		name := systemd.FieldName(key)
+		if name == "" {
+			continue
+		}
+
+		// Fields shouldn't be able to replace the journal's own, like MESSAGE.
+		if _, ok := journalFields[name]; ok {
+			name = "I3ADC_" + name
+		}
+
+		journalFields[name] = journalValue(value)
+	}
+
+	return c.journal.Send(journalFields)
+}
+
+// Sync does nothing, as entries are sent to the journal as they're written.
+func (c *journalCore) Sync() error {
+	return nil
+}
+
+// journalValue returns the given field value as a string. Anything more complex than a string or
+// number is encoded as JSON.
+func journalValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	}
+
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(bs)
+}
@@ -0,0 +1,79 @@
+package zap
+
+import (
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// moduleKey is the field that loggers set to say which module they belong to.
+const moduleKey = "module"
+
+// moduleCore is a zapcore.Core that decides which entries are enabled based on the module field
+// added to it's logger with With, allowing the level to be overridden per module. The wrapped core
+// must enable every level that any module may use.
+type moduleCore struct {
+	zapcore.Core
+
+	level    zapcore.Level
+	defLevel zapcore.Level
+	levels   map[string]zapcore.Level
+}
+
+// newModuleCore returns a new module core, wrapping the given core. Entries from loggers without a
+// module, or with a module that has no level of it's own, use the given default level.
+func newModuleCore(core zapcore.Core, defLevel zapcore.Level, levels map[string]zapcore.Level) *moduleCore {
+	return &moduleCore{
+		Core:     core,
+		level:    defLevel,
+		defLevel: defLevel,
+		levels:   levels,
+	}
+}
+
+// Enabled returns true if the given level is enabled for this core's module.
+func (c *moduleCore) Enabled(level zapcore.Level) bool {
+	return level >= c.level
+}
+
+// With returns a copy of this core with the given fields added. If one of them is the module
+// field, the copy uses that module's level.
+func (c *moduleCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+
+	for _, field := range fields {
+		if field.Key == moduleKey && field.Type == zapcore.StringType {
+			clone.level = c.levelFor(field.String)
+		}
+	}
+
+	return &clone
+}
+
+// Check adds the wrapped core to the given checked entry, if the entry's level is enabled for this
+// core's module.
+func (c *moduleCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) {
+		return checked
+	}
+
+	return c.Core.Check(entry, checked)
+}
+
+// levelFor returns the level for the given module. The most specific override wins, e.g. for
+// "xrandr/manager", an override for "xrandr/manager" is used over one for "xrandr".
+func (c *moduleCore) levelFor(module string) zapcore.Level {
+	for {
+		if level, ok := c.levels[module]; ok {
+			return level
+		}
+
+		i := strings.LastIndex(module, "/")
+		if i < 0 {
+			return c.defLevel
+		}
+
+		module = module[:i]
+	}
+}
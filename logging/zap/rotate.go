@@ -0,0 +1,125 @@
+package zap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// megabyte is the unit that file sizes are configured in.
+const megabyte = 1024 * 1024
+
+// rotatingFile is a zapcore.WriteSyncer that writes to a file, and rotates it once it would grow
+// beyond a maximum size. Rotated files have a number appended to their name, with ".1" being the
+// most recent, and only a limited number are kept.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// newRotatingFile opens (or creates) the file at the given path for appending, rotating it once it
+// would grow beyond the given size in bytes, and keeping the given number of rotated files.
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	err = f.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Write writes the given bytes to the file, rotating it first if it would grow too big. An entry
+// is never split across files.
+func (f *rotatingFile) Write(bs []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(bs)) > f.maxSize {
+		err := f.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(bs)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Sync flushes the file to disk.
+func (f *rotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Sync()
+}
+
+// open opens the file for appending, noting it's current size.
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("zap: failed to open log file: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("zap: failed to stat log file: %v", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+// rotate closes the current file, shifts each rotated file along by one, dropping the oldest, and
+// then opens a new file.
+func (f *rotatingFile) rotate() error {
+	err := f.file.Close()
+	if err != nil {
+		return err
+	}
+
+	if f.maxBackups <= 0 {
+		err = os.Remove(f.path)
+	} else {
+		// Older files are shifted along on a best effort basis; some may not exist yet.
+		os.Remove(f.backupPath(f.maxBackups))
+
+		for i := f.maxBackups - 1; i > 0; i-- {
+			os.Rename(f.backupPath(i), f.backupPath(i+1))
+		}
+
+		err = os.Rename(f.path, f.backupPath(1))
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("zap: failed to rotate log file: %v", err)
+	}
+
+	return f.open()
+}
+
+// backupPath returns the path of the rotated file with the given number.
+func (f *rotatingFile) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
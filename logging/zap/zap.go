@@ -1,10 +1,14 @@
 package zap
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"syscall"
 
 	"github.com/seeruk/i3adc/logging"
+	"github.com/seeruk/i3adc/state"
+	"github.com/seeruk/i3adc/systemd"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"golang.org/x/crypto/ssh/terminal"
@@ -20,31 +24,140 @@ var levelMap = map[logging.Level]zapcore.Level{
 }
 
 // New returns a new uber-go/zap instance, with some sensible automation around it's configuration
-// set up.
-func New(config Config) *zap.Logger {
+// set up. An error is returned if the configured output can't be opened.
+func New(config Config) (*zap.Logger, error) {
+	defLevel := zapLevel(config.Level)
+
+	// The underlying core has to let through anything that any module might log, and the module
+	// core then filters entries by their module's level.
+	minLevel := defLevel
+	levels := make(map[string]zapcore.Level, len(config.Modules))
+	for module, level := range config.Modules {
+		levels[module] = zapLevel(level)
+		if levels[module] < minLevel {
+			minLevel = levels[module]
+		}
+	}
+
+	enabler := zap.LevelEnablerFunc(func(level zapcore.Level) bool {
+		return level >= minLevel
+	})
+
+	core, err := newCore(config, enabler)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(levels) > 0 {
+		core = newModuleCore(core, defLevel, levels)
+	}
+
+	return zap.New(core), nil
+}
+
+// newCore returns the core that writes entries to the configured output, in the configured format.
+func newCore(config Config, enabler zapcore.LevelEnabler) (zapcore.Core, error) {
+	if useJournal(config) {
+		return newJournalCore(systemd.NewJournal(systemd.DefaultJournalSocket), enabler), nil
+	}
+
+	var writer zapcore.WriteSyncer
+
+	switch config.Output {
+	case OutputStderr:
+		writer = os.Stderr
+	case OutputFile:
+		path, err := filePath(config.File)
+		if err != nil {
+			return nil, err
+		}
+
+		writer, err = newRotatingFile(path, int64(config.File.MaxSize)*megabyte, config.File.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		writer = os.Stdout
+	}
+
 	// If we are running in production, stdin will not be a terminal. Otherwise, we should use a
-	// more friendly looking output style.
+	// more friendly looking output style, unless a format has been chosen explicitly. Files are
+	// never a terminal, whatever stdin is.
+	useConsole := isTerminal() && config.Output != OutputFile
+	switch config.Format {
+	case FormatConsole:
+		useConsole = true
+	case FormatJSON:
+		useConsole = false
+	}
+
 	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
-	if isTerminal() {
+	if useConsole {
 		encoderConf := zap.NewDevelopmentEncoderConfig()
-		encoderConf.EncodeLevel = zapcore.CapitalColorLevelEncoder
 		encoderConf.MessageKey = "message"
 
+		// Colours only make sense in a terminal.
+		if config.Output != OutputFile {
+			encoderConf.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+
 		encoder = zapcore.NewConsoleEncoder(encoderConf)
 	}
 
-	writer := os.Stdout
+	return zapcore.NewCore(encoder, writer, enabler), nil
+}
 
-	minLevel, ok := levelMap[config.Level]
-	if !ok {
-		minLevel = zapcore.InfoLevel
+// useJournal returns true if logs should be sent straight to the journal. Unless the journal is
+// chosen as the output, they're only sent there automatically if they would've ended up there
+// anyway, and if journald can be reached.
+func useJournal(config Config) bool {
+	var writer *os.File
+
+	switch {
+	case config.Output == OutputJournal:
+		return true
+	case config.Format != FormatAuto:
+		return false
+	case config.Output == OutputStdout:
+		writer = os.Stdout
+	case config.Output == OutputStderr:
+		writer = os.Stderr
+	default:
+		return false
 	}
 
-	enabler := zap.LevelEnablerFunc(func(level zapcore.Level) bool {
-		return level >= minLevel
-	})
+	if !systemd.IsJournalStream(writer) {
+		return false
+	}
+
+	_, err := os.Stat(systemd.DefaultJournalSocket)
+
+	return err == nil
+}
+
+// filePath returns the path of the log file, which defaults to "i3adc.log" in i3adc's local
+// directory.
+func filePath(config FileConfig) (string, error) {
+	if config.Path != "" {
+		return config.Path, nil
+	}
+
+	localDir, err := state.LocalDirectory()
+	if err != nil {
+		return "", fmt.Errorf("zap: failed to get local directory: %v", err)
+	}
+
+	return filepath.Join(localDir, "i3adc.log"), nil
+}
+
+// zapLevel returns the zap level for the given level, defaulting to info.
+func zapLevel(level logging.Level) zapcore.Level {
+	zapLevel, ok := levelMap[level]
+	if !ok {
+		return zapcore.InfoLevel
+	}
 
-	return zap.New(zapcore.NewCore(encoder, writer, enabler))
+	return zapLevel
 }
 
 // isTerminal will return true if the application's stdin appears to be a terminal.
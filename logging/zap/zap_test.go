@@ -0,0 +1,145 @@
+package zap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seeruk/i3adc/logging"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i3adc-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "i3adc.log")
+
+	t.Run("should rotate the file once it would grow too big", func(t *testing.T) {
+		file, err := newRotatingFile(path, 10, 2)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		for _, entry := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+			_, err := file.Write([]byte(entry))
+			assert.NoError(t, err)
+		}
+
+		assert.NoError(t, file.Sync())
+
+		assertFile(t, "fourth\n", path)
+		assertFile(t, "third\n", path+".1")
+		assertFile(t, "second\n", path+".2")
+
+		_, err = os.Stat(path + ".3")
+		assert.True(t, os.IsNotExist(err), "expected oldest file to be removed")
+	})
+
+	t.Run("should append to an existing file", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("old\n"), 0600))
+
+		file, err := newRotatingFile(path, 1024, 1)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = file.Write([]byte("new\n"))
+		assert.NoError(t, err)
+
+		assertFile(t, "old\nnew\n", path)
+	})
+}
+
+func TestModuleCore(t *testing.T) {
+	observed, logs := observer.New(zapcore.DebugLevel)
+
+	levels := map[string]zapcore.Level{
+		"xrandr":         zapcore.DebugLevel,
+		"xrandr/thread":  zapcore.ErrorLevel,
+		"control/server": zapcore.WarnLevel,
+	}
+
+	logger := zap.New(newModuleCore(observed, zapcore.InfoLevel, levels)).Sugar()
+
+	tests := []struct {
+		module   string
+		expected []string
+	}{
+		{module: "", expected: []string{"info", "warn", "error"}},
+		{module: "xrandr/manager", expected: []string{"debug", "info", "warn", "error"}},
+		{module: "xrandr/thread", expected: []string{"error"}},
+		{module: "control/server", expected: []string{"warn", "error"}},
+		{module: "i3/thread", expected: []string{"info", "warn", "error"}},
+	}
+
+	for _, test := range tests {
+		t.Run("should use the level for module "+test.module, func(t *testing.T) {
+			moduleLogger := logger
+			if test.module != "" {
+				moduleLogger = logger.With("module", test.module)
+			}
+
+			moduleLogger.Debug("debug")
+			moduleLogger.Info("info")
+			moduleLogger.Warn("warn")
+			moduleLogger.Error("error")
+
+			var messages []string
+			for _, entry := range logs.TakeAll() {
+				messages = append(messages, entry.Message)
+			}
+
+			assert.Equal(t, test.expected, messages)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i3adc-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	t.Run("should write to a file", func(t *testing.T) {
+		path := filepath.Join(dir, "logs", "i3adc.log")
+
+		logger, err := New(Config{
+			Level:   logging.InfoLevel,
+			Format:  FormatJSON,
+			Output:  OutputFile,
+			File:    FileConfig{Path: path, MaxSize: 1},
+			Modules: map[string]logging.Level{"xrandr": logging.DebugLevel},
+		})
+
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		logger.Sugar().With("module", "xrandr/manager").Debug("applied layout")
+		logger.Sugar().Debug("hidden")
+
+		bs, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Contains(t, string(bs), `"msg":"applied layout"`)
+		assert.NotContains(t, string(bs), "hidden")
+	})
+}
+
+// assertFile asserts that the file at the given path has the given contents.
+func assertFile(t *testing.T, expected, path string) {
+	bs, err := ioutil.ReadFile(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, string(bs))
+	}
+}
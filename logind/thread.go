@@ -0,0 +1,186 @@
+// Package logind listens to logind on the system D-Bus, so that i3adc can look at the outputs again
+// when the machine resumes from sleep. Monitors often come back in odd states after resuming, and
+// i3 doesn't always send an output event for them.
+package logind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/seeruk/i3adc/event"
+	"github.com/seeruk/i3adc/logging"
+)
+
+const (
+	// signalName is the name of the signal logind sends before the machine goes to sleep, and after
+	// it resumes.
+	signalName = "org.freedesktop.login1.Manager.PrepareForSleep"
+	// matchRule asks the bus to send us logind's sleep signals.
+	matchRule = "type='signal',interface='org.freedesktop.login1.Manager',member='PrepareForSleep'"
+)
+
+// Thread is a background thread that listens for logind's PrepareForSleep signal, and publishes a
+// resume event to the event bus a little while after the machine resumes, once the outputs have
+// had a chance to come back. If the connection to the bus is lost, the thread fails.
+type Thread struct {
+	address string
+	delay   time.Duration
+	bus     *event.Bus
+	logger  logging.Logger
+
+	mu  sync.Mutex
+	ctx context.Context
+	cfn context.CancelFunc
+}
+
+// NewThread returns a new logind thread instance, that connects to the D-Bus at the given address,
+// or the system bus if it's empty, and publishes resume events to the given bus after the given
+// delay.
+func NewThread(address string, delay time.Duration, bus *event.Bus, logger logging.Logger) *Thread {
+	logger = logger.With("module", "logind/thread")
+
+	return &Thread{
+		address: address,
+		delay:   delay,
+		bus:     bus,
+		logger:  logger,
+	}
+}
+
+// Start connects to the bus, and waits for the machine to resume from sleep. It returns when the
+// thread is stopped, or an error if the connection to the bus is lost.
+func (t *Thread) Start() error {
+	t.logger.Info("thread started")
+
+	t.mu.Lock()
+	t.ctx, t.cfn = context.WithCancel(context.Background())
+	ctx := t.ctx
+	t.mu.Unlock()
+
+	conn, err := t.connect()
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	defer func() {
+		// Signals are delivered while holding a lock that closing the connection needs, so any
+		// that are still on their way have to be received first.
+		go func() {
+			for range signals {
+			}
+		}()
+
+		conn.Close()
+	}()
+
+	err = conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, matchRule).Err
+	if err != nil {
+		return fmt.Errorf("logind: failed to listen for sleep signals: %v", err)
+	}
+
+	// The timer only runs between resuming and the resume event being published.
+	timer := time.NewTimer(t.delay)
+	stopTimer(timer)
+
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.logger.Info("thread stopped")
+			return nil
+		case sig, ok := <-signals:
+			if !ok {
+				return errors.New("logind: lost connection to the bus")
+			}
+
+			sleeping, ok := parseSignal(sig)
+			if !ok {
+				continue
+			}
+
+			stopTimer(timer)
+
+			if sleeping {
+				t.logger.Debug("preparing for sleep")
+				continue
+			}
+
+			t.logger.Infow("resumed from sleep", "delay", t.delay.String())
+			timer.Reset(t.delay)
+		case <-timer.C:
+			t.bus.Publish(event.New(event.SourceLogind, event.ReasonResume))
+		}
+	}
+}
+
+// Stop attempts to stop this thread.
+func (t *Thread) Stop() error {
+	t.logger.Infow("thread stopping")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ctx != nil && t.cfn != nil {
+		t.cfn()
+	}
+
+	return nil
+}
+
+// connect opens a private connection to the bus, and authenticates with it.
+func (t *Thread) connect() (*dbus.Conn, error) {
+	var conn *dbus.Conn
+	var err error
+
+	if t.address == "" {
+		conn, err = dbus.SystemBusPrivate()
+	} else {
+		conn, err = dbus.Dial(t.address)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("logind: failed to connect to the bus: %v", err)
+	}
+
+	err = conn.Auth(nil)
+	if err == nil {
+		err = conn.Hello()
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("logind: failed to authenticate with the bus: %v", err)
+	}
+
+	return conn, nil
+}
+
+// parseSignal returns whether the machine is going to sleep (true) or resuming (false), if the
+// given signal is a PrepareForSleep signal. Otherwise, false is returned as the second value.
+func parseSignal(sig *dbus.Signal) (bool, bool) {
+	if sig.Name != signalName || len(sig.Body) != 1 {
+		return false, false
+	}
+
+	sleeping, ok := sig.Body[0].(bool)
+
+	return sleeping, ok
+}
+
+// stopTimer stops the given timer, draining it's channel if it had already fired.
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
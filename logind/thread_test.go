@@ -0,0 +1,177 @@
+package logind
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/seeruk/i3adc/daemon"
+	"github.com/seeruk/i3adc/event"
+	"github.com/seeruk/i3adc/logging/noop"
+	"github.com/stretchr/testify/assert"
+)
+
+// busConfig is the configuration of the private bus that the tests run against, which lets anyone
+// send anything.
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+func TestThread(t *testing.T) {
+	address, stop := startBus(t)
+	defer stop()
+
+	conn, err := dbus.Dial(address)
+	if err == nil {
+		err = conn.Auth(nil)
+	}
+
+	if err == nil {
+		err = conn.Hello()
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	// emit sends logind's PrepareForSleep signal to the bus.
+	emit := func(sleeping bool) {
+		err := conn.Emit("/org/freedesktop/login1", signalName, sleeping)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bus := event.NewBus(noop.NewLogger())
+	sub := bus.Subscribe("test", 10, event.DropOldest)
+
+	ctx, cfn := context.WithCancel(context.Background())
+	defer cfn()
+
+	daemon.NewBackgroundThread(ctx, NewThread(address, 10*time.Millisecond, bus, noop.NewLogger()))
+
+	t.Run("should publish a resume event after resuming", func(t *testing.T) {
+		// The thread may not be listening yet, so the signal is sent until it notices.
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+
+		timeout := time.After(5 * time.Second)
+
+		for {
+			emit(false)
+
+			select {
+			case evt := <-sub.Events():
+				assert.Equal(t, event.SourceLogind, evt.Source)
+				assert.Equal(t, event.ReasonResume, evt.Reason)
+				return
+			case <-timeout:
+				t.Fatal("timed out waiting for resume event")
+			case <-ticker.C:
+			}
+		}
+	})
+
+	t.Run("should not publish anything when going to sleep", func(t *testing.T) {
+		// Drain any events from signals sent while waiting for the thread to start listening.
+		time.Sleep(100 * time.Millisecond)
+		for len(sub.Events()) > 0 {
+			<-sub.Events()
+		}
+
+		emit(true)
+
+		select {
+		case evt := <-sub.Events():
+			t.Errorf("unexpected event: %+v", evt)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("should wait for the delay after resuming", func(t *testing.T) {
+		emit(false)
+		start := time.Now()
+
+		select {
+		case <-sub.Events():
+			assert.True(t, time.Since(start) >= 10*time.Millisecond)
+		case <-time.After(5 * time.Second):
+			t.Error("timed out waiting for resume event")
+		}
+	})
+}
+
+func TestThreadFailsWithoutBus(t *testing.T) {
+	t.Run("should return an error if the bus can't be reached", func(t *testing.T) {
+		thread := NewThread("unix:path=/nonexistent/bus", 0, nil, noop.NewLogger())
+		assert.Error(t, thread.Start())
+	})
+}
+
+// startBus starts a private dbus-daemon for the test, returning it's address, and a function that
+// stops it. The test is skipped if dbus-daemon isn't installed.
+func startBus(t *testing.T) (string, func()) {
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "i3adc-dbus")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configPath := filepath.Join(dir, "bus.conf")
+	config := fmt.Sprintf(busConfig, filepath.Join(dir, "bus"))
+
+	err = ioutil.WriteFile(configPath, []byte(config), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(path, "--config-file="+configPath, "--nofork", "--print-address")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		os.RemoveAll(dir)
+	}
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		stop()
+		t.Fatal(err)
+	}
+
+	return strings.TrimSpace(address), stop
+}
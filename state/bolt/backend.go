@@ -1,6 +1,8 @@
 package bolt
 
 import (
+	"bytes"
+
 	"github.com/coreos/bbolt"
 	"github.com/seeruk/i3adc/state"
 )
@@ -70,3 +72,21 @@ func (b *Backend) Delete(key string) error {
 		return bucket.Delete([]byte(key))
 	})
 }
+
+// Keys returns all keys in the underlying bolt bucket that start with the given prefix.
+func (b *Backend) Keys(prefix string) ([]string, error) {
+	var keys []string
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket([]byte(BucketOutputLayouts)).Cursor()
+		prefixBS := []byte(prefix)
+
+		for k, _ := cursor.Seek(prefixBS); k != nil && bytes.HasPrefix(k, prefixBS); k, _ = cursor.Next() {
+			keys = append(keys, string(k))
+		}
+
+		return nil
+	})
+
+	return keys, err
+}
@@ -10,21 +10,26 @@ import (
 	"github.com/seeruk/i3adc/state"
 )
 
-// OpenDB attempts to open the bolt-based database, returning a new bolt DB instance.
-func OpenDB() (*bolt.DB, error) {
-	localDir, err := state.LocalDirectory()
-	if err != nil {
-		return nil, fmt.Errorf("bolt: failed to get local directory: %v", err)
+// OpenDB attempts to open the bolt-based database at the given path, returning a new bolt DB
+// instance. If the path is empty, "i3adc.db" in i3adc's local directory is used.
+func OpenDB(path string) (*bolt.DB, error) {
+	if path == "" {
+		localDir, err := state.LocalDirectory()
+		if err != nil {
+			return nil, fmt.Errorf("bolt: failed to get local directory: %v", err)
+		}
+
+		path = filepath.Join(localDir, "i3adc.db")
 	}
 
-	// Make the local directory if it does not exist.
-	err = os.MkdirAll(localDir, os.ModePerm)
+	// Make the database's directory if it does not exist.
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
 	if err != nil {
 		return nil, err
 	}
 
 	// Open / create as 0700 so that we (and only we) have read/write access.
-	return bolt.Open(filepath.Join(localDir, "i3adc.db"), 0600, &bolt.Options{
+	return bolt.Open(path, 0600, &bolt.Options{
 		Timeout: 5 * time.Second,
 	})
 }
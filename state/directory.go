@@ -11,7 +11,7 @@ import (
 // LocalDirectory returns the directory that i3adc will use to store all configuration / data for
 // the current user.
 func LocalDirectory() (string, error) {
-	home, err := homeDirectory()
+	home, err := HomeDirectory()
 	if err != nil {
 		return "", fmt.Errorf("state: couldn't get local directory to use: %v", err)
 	}
@@ -19,8 +19,8 @@ func LocalDirectory() (string, error) {
 	return filepath.Join(home, ".i3adc"), nil
 }
 
-// homeDirectory attempts to get the current user's home directory.
-func homeDirectory() (string, error) {
+// HomeDirectory attempts to get the current user's home directory.
+func HomeDirectory() (string, error) {
 	// Try to use the environment first.
 	if home := os.Getenv("HOME"); home != "" {
 		return home, nil
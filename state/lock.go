@@ -0,0 +1,147 @@
+package state
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// LockedError is returned when trying to acquire a lock that another process holds.
+type LockedError struct {
+	// PID is the process ID of the process holding the lock, if it's known, or 0 otherwise.
+	PID int
+}
+
+// Error implements the error interface for LockedError.
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return "state: i3adc is already running"
+	}
+
+	return fmt.Sprintf("state: i3adc is already running as PID %d", e.PID)
+}
+
+// Lock is an exclusive lock on a file, held by this process, that contains it's PID. The lock is
+// released by the OS if the process dies, so a lock file left behind doesn't block anything.
+type Lock struct {
+	file *os.File
+}
+
+// LockPath returns the path of the lock file used to make sure only one i3adc daemon runs at once
+// for the current user.
+func LockPath() (string, error) {
+	localDir, err := LocalDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(localDir, "i3adc.pid"), nil
+}
+
+// AcquireLock attempts to take the lock on the file at the given path, without waiting. If another
+// process holds the lock, a *LockedError is returned.
+func AcquireLock(path string) (*Lock, error) {
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	var file *os.File
+	for {
+		file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("state: failed to open lock file: %v", err)
+		}
+
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == syscall.EWOULDBLOCK {
+			file.Close()
+			return nil, &LockedError{PID: readPID(path)}
+		}
+
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("state: failed to lock %q: %v", path, err)
+		}
+
+		// The process that held the lock may have removed the file after we opened it, in which
+		// case we've locked a file nobody else can see, and have to try again.
+		if isSameFile(file, path) {
+			break
+		}
+
+		file.Close()
+	}
+
+	err = writePID(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("state: failed to write PID to lock file: %v", err)
+	}
+
+	return &Lock{
+		file: file,
+	}, nil
+}
+
+// Release removes the lock file, and releases the lock.
+func (l *Lock) Release() error {
+	// The file is removed while it's still locked, so that another process can't lock it in the
+	// meantime, only to have it removed from under it.
+	err := os.Remove(l.file.Name())
+	if err != nil && !os.IsNotExist(err) {
+		l.file.Close()
+		return err
+	}
+
+	return l.file.Close()
+}
+
+// isSameFile returns true if the given open file is still the file at the given path.
+func isSameFile(file *os.File, path string) bool {
+	openInfo, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	pathInfo, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(openInfo, pathInfo)
+}
+
+// readPID reads the PID from the lock file at the given path. If it can't be read, 0 is returned.
+func readPID(path string) int {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(bs)))
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
+
+// writePID replaces the contents of the given file with the PID of this process.
+func writePID(file *os.File) error {
+	err := file.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	if err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
@@ -0,0 +1,75 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i3adc-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "i3adc.pid")
+
+	t.Run("should write this process' PID to the lock file", func(t *testing.T) {
+		lock, err := AcquireLock(path)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		defer lock.Release()
+
+		bs, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(bs))
+	})
+
+	t.Run("should report the PID of the holder if the lock is held", func(t *testing.T) {
+		lock, err := AcquireLock(path)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		defer lock.Release()
+
+		_, err = AcquireLock(path)
+
+		assert.Equal(t, &LockedError{PID: os.Getpid()}, err)
+		assert.EqualError(t, err, "state: i3adc is already running as PID "+strconv.Itoa(os.Getpid()))
+	})
+
+	t.Run("should be able to acquire the lock once it's released", func(t *testing.T) {
+		lock, err := AcquireLock(path)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.NoError(t, lock.Release())
+
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+
+		lock, err = AcquireLock(path)
+		if assert.NoError(t, err) {
+			lock.Release()
+		}
+	})
+
+	t.Run("should ignore a lock file left behind by a process that died", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("12345\n"), 0600))
+
+		lock, err := AcquireLock(path)
+		if assert.NoError(t, err) {
+			lock.Release()
+		}
+	})
+}
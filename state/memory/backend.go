@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seeruk/i3adc/state"
+)
+
+var _ state.Backend = (*Backend)(nil)
+
+// Backend is an in-memory implementation of i3adc's state backend interface. Nothing is persisted,
+// so it's mostly useful for testing.
+type Backend struct {
+	sync.RWMutex
+
+	values map[string][]byte
+}
+
+// NewBackend returns a new, empty, in-memory backend instance.
+func NewBackend() *Backend {
+	return &Backend{
+		values: make(map[string][]byte),
+	}
+}
+
+// Read returns the value stored under the given key, or nil if there isn't one.
+func (b *Backend) Read(key string) ([]byte, error) {
+	if key == "" {
+		return nil, state.ErrInvalidKey
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+
+	return b.values[key], nil
+}
+
+// Write stores the given value under the given key.
+func (b *Backend) Write(key string, val []byte) error {
+	if key == "" {
+		return state.ErrInvalidKey
+	}
+
+	if val == nil {
+		return state.ErrInvalidValue
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.values[key] = val
+
+	return nil
+}
+
+// Delete removes the value stored under the given key.
+func (b *Backend) Delete(key string) error {
+	b.Lock()
+	defer b.Unlock()
+
+	delete(b.values, key)
+
+	return nil
+}
+
+// Keys returns all keys that start with the given prefix, in sorted order.
+func (b *Backend) Keys(prefix string) ([]string, error) {
+	b.RLock()
+	defer b.RUnlock()
+
+	var keys []string
+	for key := range b.values {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
@@ -21,4 +21,7 @@ type Backend interface {
 	Read(key string) ([]byte, error)
 	Write(key string, val []byte) error
 	Delete(key string) error
+	// Keys returns all keys that start with the given prefix, in byte-sorted order. An empty
+	// prefix will return every key.
+	Keys(prefix string) ([]string, error)
 }
@@ -0,0 +1,155 @@
+package systemd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// DefaultJournalSocket is where journald listens for entries sent using it's native protocol.
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+// Syslog priorities, used as the PRIORITY field of journal entries.
+const (
+	PriorityCrit    = 2
+	PriorityErr     = 3
+	PriorityWarning = 4
+	PriorityInfo    = 6
+	PriorityDebug   = 7
+)
+
+// Journal sends structured entries to journald, using it's native protocol (see
+// systemd-journald.service(8)). It's safe for concurrent use.
+type Journal struct {
+	addr *net.UnixAddr
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+// NewJournal returns a Journal that sends entries to the socket at the given path.
+func NewJournal(path string) *Journal {
+	return &Journal{
+		addr: &net.UnixAddr{Name: path, Net: "unixgram"},
+	}
+}
+
+// Send sends a single entry, made of the given fields, to the journal. Field names should already
+// be valid journal field names (see FieldName).
+func (j *Journal) Send(fields map[string]string) error {
+	entry := encodeEntry(fields)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// journald may have been restarted since the last entry was sent, so a failed write gets one
+	// more try, on a new connection.
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if j.conn == nil {
+			j.conn, err = net.DialUnix(j.addr.Net, nil, j.addr)
+			if err != nil {
+				return fmt.Errorf("systemd: failed to connect to journal: %v", err)
+			}
+		}
+
+		_, err = j.conn.Write(entry)
+		if err == nil {
+			return nil
+		}
+
+		j.conn.Close()
+		j.conn = nil
+	}
+
+	return fmt.Errorf("systemd: failed to send journal entry: %v", err)
+}
+
+// Close closes the connection to the journal, if there is one.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.conn == nil {
+		return nil
+	}
+
+	err := j.conn.Close()
+	j.conn = nil
+
+	return err
+}
+
+// FieldName turns the given name into a valid journal field name. Journal field names may only
+// contain upper case letters, digits, and underscores, and can't start with an underscore or a
+// digit.
+func FieldName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+
+		return '_'
+	}, name)
+
+	return strings.TrimLeft(name, "_0123456789")
+}
+
+// IsJournalStream returns true if the given file is connected to the journal, i.e. systemd has
+// connected it to journald, and set JOURNAL_STREAM to say so.
+func IsJournalStream(file *os.File) bool {
+	var dev, ino uint64
+
+	_, err := fmt.Sscanf(os.Getenv(EnvJournalStream), "%d:%d", &dev, &ino)
+	if err != nil {
+		return false
+	}
+
+	var stat syscall.Stat_t
+
+	err = syscall.Fstat(int(file.Fd()), &stat)
+	if err != nil {
+		return false
+	}
+
+	return uint64(stat.Dev) == dev && uint64(stat.Ino) == ino
+}
+
+// encodeEntry encodes the given fields as a journal entry. Fields are sorted by name, so entries
+// are always encoded the same way.
+func encodeEntry(fields map[string]string) []byte {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		value := fields[name]
+
+		// Values containing newlines have to be sent with their length, instead of after "=".
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&buf, "%s=%s\n", name, value)
+			continue
+		}
+
+		buf.WriteString(name)
+		buf.WriteByte('\n')
+		binary.Write(&buf, binary.LittleEndian, uint64(len(value)))
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,92 @@
+package systemd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal(t *testing.T) {
+	t.Run("should send entries to the journal socket", func(t *testing.T) {
+		conn, path := listenUnixgram(t)
+		defer conn.Close()
+
+		journal := NewJournal(path)
+		defer journal.Close()
+
+		err := journal.Send(map[string]string{
+			"MESSAGE":  "applied layout",
+			"PRIORITY": "6",
+			"MODULE":   "xrandr/manager",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "MESSAGE=applied layout\nMODULE=xrandr/manager\nPRIORITY=6\n", readDatagram(t, conn))
+	})
+
+	t.Run("should send values containing newlines with their length", func(t *testing.T) {
+		conn, path := listenUnixgram(t)
+		defer conn.Close()
+
+		journal := NewJournal(path)
+		defer journal.Close()
+
+		assert.NoError(t, journal.Send(map[string]string{"MESSAGE": "a\nb"}))
+		assert.Equal(t, "MESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n", readDatagram(t, conn))
+	})
+}
+
+func TestFieldName(t *testing.T) {
+	tests := map[string]string{
+		"module":        "MODULE",
+		"previous_hash": "PREVIOUS_HASH",
+		"backoff-time":  "BACKOFF_TIME",
+		"_private":      "PRIVATE",
+		"2fa":           "FA",
+		"...":           "",
+	}
+
+	for name, expected := range tests {
+		t.Run(fmt.Sprintf("should turn %q into %q", name, expected), func(t *testing.T) {
+			assert.Equal(t, expected, FieldName(name))
+		})
+	}
+}
+
+func TestIsJournalStream(t *testing.T) {
+	defer os.Setenv(EnvJournalStream, os.Getenv(EnvJournalStream))
+
+	file, err := ioutil.TempFile("", "i3adc-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat := info.Sys().(*syscall.Stat_t)
+
+	t.Run("should match a file with the device and inode from the environment", func(t *testing.T) {
+		os.Setenv(EnvJournalStream, fmt.Sprintf("%d:%d", stat.Dev, stat.Ino))
+		assert.True(t, IsJournalStream(file))
+	})
+
+	t.Run("should not match any other file", func(t *testing.T) {
+		os.Setenv(EnvJournalStream, fmt.Sprintf("%d:%d", stat.Dev, stat.Ino+1))
+		assert.False(t, IsJournalStream(file))
+	})
+
+	t.Run("should not match anything if not set", func(t *testing.T) {
+		os.Unsetenv(EnvJournalStream)
+		assert.False(t, IsJournalStream(file))
+	})
+}
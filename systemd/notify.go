@@ -0,0 +1,110 @@
+// Package systemd lets i3adc cooperate with systemd when it's run as a service, by reporting it's
+// readiness and liveness, and logging to the journal.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables set by systemd for services.
+const (
+	EnvNotifySocket  = "NOTIFY_SOCKET"
+	EnvWatchdogUSec  = "WATCHDOG_USEC"
+	EnvWatchdogPID   = "WATCHDOG_PID"
+	EnvJournalStream = "JOURNAL_STREAM"
+)
+
+// Notifier sends service state notifications to systemd (see sd_notify(3)). A nil Notifier is
+// valid, and silently does nothing, so that it can be used whether or not i3adc was started by
+// systemd.
+type Notifier struct {
+	addr *net.UnixAddr
+}
+
+// NewNotifier returns a Notifier that sends notifications to the socket named by the
+// NOTIFY_SOCKET environment variable. If it isn't set, nil is returned.
+func NewNotifier() *Notifier {
+	path := os.Getenv(EnvNotifySocket)
+	if path == "" {
+		return nil
+	}
+
+	return NewNotifierAt(path)
+}
+
+// NewNotifierAt returns a Notifier that sends notifications to the socket at the given path. A
+// path starting with "@" refers to a socket in the abstract namespace.
+func NewNotifierAt(path string) *Notifier {
+	if strings.HasPrefix(path, "@") {
+		path = "\x00" + path[1:]
+	}
+
+	return &Notifier{
+		addr: &net.UnixAddr{Name: path, Net: "unixgram"},
+	}
+}
+
+// Notify sends the given newline-separated variable assignments to systemd, e.g. "READY=1".
+func (n *Notifier) Notify(state string) error {
+	if n == nil {
+		return nil
+	}
+
+	conn, err := net.DialUnix(n.addr.Net, nil, n.addr)
+	if err != nil {
+		return fmt.Errorf("systemd: failed to connect to notify socket: %v", err)
+	}
+
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	if err != nil {
+		return fmt.Errorf("systemd: failed to send notification: %v", err)
+	}
+
+	return nil
+}
+
+// Ready tells systemd that startup has finished.
+func (n *Notifier) Ready() error {
+	return n.Notify("READY=1")
+}
+
+// Stopping tells systemd that the service is shutting down.
+func (n *Notifier) Stopping() error {
+	return n.Notify("STOPPING=1")
+}
+
+// Status sets the single line status shown by systemctl for the service.
+func (n *Notifier) Status(status string) error {
+	// A newline would start a new assignment, so the status has to be kept to one line.
+	return n.Notify("STATUS=" + strings.Replace(status, "\n", " ", -1))
+}
+
+// Watchdog tells systemd that the service is still alive.
+func (n *Notifier) Watchdog() error {
+	return n.Notify("WATCHDOG=1")
+}
+
+// WatchdogInterval returns how often systemd expects to hear from the service's watchdog, from the
+// WATCHDOG_USEC environment variable. If the watchdog isn't enabled for this process, 0 is
+// returned.
+func WatchdogInterval() time.Duration {
+	// If the PID is set, the watchdog is meant for a specific process, which may not be this one.
+	pid := os.Getenv(EnvWatchdogPID)
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv(EnvWatchdogUSec), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
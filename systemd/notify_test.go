@@ -0,0 +1,126 @@
+package systemd
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifier(t *testing.T) {
+	t.Run("should send notifications to the notify socket", func(t *testing.T) {
+		conn, path := listenUnixgram(t)
+		defer conn.Close()
+
+		notifier := NewNotifierAt(path)
+
+		assert.NoError(t, notifier.Ready())
+		assert.Equal(t, "READY=1", readDatagram(t, conn))
+
+		assert.NoError(t, notifier.Status("layout abc\nprofile work"))
+		assert.Equal(t, "STATUS=layout abc profile work", readDatagram(t, conn))
+
+		assert.NoError(t, notifier.Watchdog())
+		assert.Equal(t, "WATCHDOG=1", readDatagram(t, conn))
+
+		assert.NoError(t, notifier.Stopping())
+		assert.Equal(t, "STOPPING=1", readDatagram(t, conn))
+	})
+
+	t.Run("should use the socket named by the environment", func(t *testing.T) {
+		conn, path := listenUnixgram(t)
+		defer conn.Close()
+
+		defer os.Setenv(EnvNotifySocket, os.Getenv(EnvNotifySocket))
+		os.Setenv(EnvNotifySocket, path)
+
+		assert.NoError(t, NewNotifier().Ready())
+		assert.Equal(t, "READY=1", readDatagram(t, conn))
+	})
+
+	t.Run("should do nothing if not started by systemd", func(t *testing.T) {
+		defer os.Setenv(EnvNotifySocket, os.Getenv(EnvNotifySocket))
+		os.Unsetenv(EnvNotifySocket)
+
+		notifier := NewNotifier()
+
+		assert.Nil(t, notifier)
+		assert.NoError(t, notifier.Ready())
+	})
+
+	t.Run("should return an error if the socket can't be reached", func(t *testing.T) {
+		notifier := NewNotifierAt(filepath.Join(os.TempDir(), "i3adc-missing.sock"))
+
+		assert.Error(t, notifier.Ready())
+	})
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Setenv(EnvWatchdogUSec, os.Getenv(EnvWatchdogUSec))
+	defer os.Setenv(EnvWatchdogPID, os.Getenv(EnvWatchdogPID))
+
+	t.Run("should read the interval from the environment", func(t *testing.T) {
+		os.Setenv(EnvWatchdogUSec, "30000000")
+		os.Unsetenv(EnvWatchdogPID)
+
+		assert.Equal(t, 30*time.Second, WatchdogInterval())
+	})
+
+	t.Run("should accept a watchdog meant for this process", func(t *testing.T) {
+		os.Setenv(EnvWatchdogUSec, "1000")
+		os.Setenv(EnvWatchdogPID, strconv.Itoa(os.Getpid()))
+
+		assert.Equal(t, time.Millisecond, WatchdogInterval())
+	})
+
+	t.Run("should ignore a watchdog meant for another process", func(t *testing.T) {
+		os.Setenv(EnvWatchdogUSec, "1000")
+		os.Setenv(EnvWatchdogPID, strconv.Itoa(os.Getpid()+1))
+
+		assert.Equal(t, time.Duration(0), WatchdogInterval())
+	})
+
+	t.Run("should return 0 if the watchdog isn't enabled", func(t *testing.T) {
+		os.Unsetenv(EnvWatchdogUSec)
+		os.Unsetenv(EnvWatchdogPID)
+
+		assert.Equal(t, time.Duration(0), WatchdogInterval())
+	})
+}
+
+// listenUnixgram listens on a new datagram socket in a temporary directory, standing in for the
+// socket that systemd would listen on.
+func listenUnixgram(t *testing.T) (*net.UnixConn, string) {
+	dir, err := ioutil.TempDir("", "i3adc-systemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn, path
+}
+
+// readDatagram reads a single datagram from the given connection.
+func readDatagram(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 4096)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(buf[:n])
+}
@@ -0,0 +1,92 @@
+package systemd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/seeruk/i3adc/logging"
+)
+
+// Watchdog is a thread that pings systemd's watchdog for as long as the service is healthy. If the
+// service stops being healthy, the pings stop, and systemd will restart the service once the
+// watchdog interval has passed.
+type Watchdog struct {
+	notifier  *Notifier
+	interval  time.Duration
+	isHealthy func() bool
+	logger    logging.Logger
+
+	mu      sync.Mutex
+	ctx     context.Context
+	cfn     context.CancelFunc
+	stopped bool
+}
+
+// NewWatchdog returns a new watchdog thread, that pings the given notifier twice within the given
+// watchdog interval, as long as the given function says the service is healthy.
+func NewWatchdog(notifier *Notifier, interval time.Duration, isHealthy func() bool, logger logging.Logger) *Watchdog {
+	logger = logger.With("module", "systemd/watchdog")
+
+	return &Watchdog{
+		notifier:  notifier,
+		interval:  interval,
+		isHealthy: isHealthy,
+		logger:    logger,
+	}
+}
+
+// Start begins pinging the watchdog, until the thread is stopped.
+func (w *Watchdog) Start() error {
+	w.logger.Infow("thread started", "interval", w.interval.String())
+
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		w.logger.Info("thread stopped")
+		return nil
+	}
+
+	w.ctx, w.cfn = context.WithCancel(context.Background())
+	ctx := w.ctx
+	w.mu.Unlock()
+
+	// Pinging at half the interval leaves room for a ping to be a little late.
+	ticker := time.NewTicker(w.interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("thread stopped")
+			return nil
+		case <-ticker.C:
+		}
+
+		if !w.isHealthy() {
+			w.logger.Warn("service is unhealthy, not pinging watchdog")
+			continue
+		}
+
+		err := w.notifier.Watchdog()
+		if err != nil {
+			w.logger.Errorw("failed to ping watchdog", "error", err)
+		}
+	}
+}
+
+// Stop attempts to stop this thread.
+func (w *Watchdog) Stop() error {
+	w.logger.Info("thread stopping")
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stopped = true
+
+	if w.cfn != nil {
+		w.cfn()
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package systemd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seeruk/i3adc/logging/noop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdog(t *testing.T) {
+	t.Run("should ping the watchdog while the service is healthy", func(t *testing.T) {
+		conn, path := listenUnixgram(t)
+		defer conn.Close()
+
+		isHealthy := func() bool { return true }
+		watchdog := NewWatchdog(NewNotifierAt(path), 10*time.Millisecond, isHealthy, noop.NewLogger())
+
+		done := make(chan error, 1)
+		go func() { done <- watchdog.Start() }()
+
+		assert.Equal(t, "WATCHDOG=1", readDatagram(t, conn))
+		assert.NoError(t, watchdog.Stop())
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("should not start if it's stopped before it starts", func(t *testing.T) {
+		isHealthy := func() bool { return false }
+		watchdog := NewWatchdog(nil, time.Hour, isHealthy, noop.NewLogger())
+
+		assert.NoError(t, watchdog.Stop())
+
+		done := make(chan error, 1)
+		go func() { done <- watchdog.Start() }()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Error("expected thread to stop")
+		}
+	})
+}
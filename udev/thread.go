@@ -0,0 +1,145 @@
+package udev
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/seeruk/i3adc/event"
+	"github.com/seeruk/i3adc/logging"
+)
+
+// kernelGroup is the netlink multicast group that the kernel sends uevents to.
+const kernelGroup = 1
+
+// maxMessageSize is big enough for any uevent; the kernel limits their variables to 2KiB.
+const maxMessageSize = 8192
+
+// Thread is a background thread that listens on the kernel's uevent netlink socket, and publishes
+// a hotplug event to the event bus whenever DRM tells us that outputs may have been connected or
+// disconnected. It's an event source alongside the i3 thread.
+type Thread struct {
+	bus    *event.Bus
+	logger logging.Logger
+
+	mu      sync.Mutex
+	file    *os.File
+	stopped bool
+}
+
+// NewThread returns a new udev thread instance, that publishes events to the given bus.
+func NewThread(bus *event.Bus, logger logging.Logger) *Thread {
+	logger = logger.With("module", "udev/thread")
+
+	return &Thread{
+		bus:    bus,
+		logger: logger,
+	}
+}
+
+// Start opens the uevent socket, and waits for DRM hotplug events. It returns when the thread is
+// stopped, or an error if the socket can't be opened or read.
+func (t *Thread) Start() error {
+	t.logger.Info("thread started")
+
+	file, err := openSocket()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		file.Close()
+		t.logger.Info("thread stopped")
+		return nil
+	}
+
+	t.file = file
+	t.mu.Unlock()
+
+	defer file.Close()
+
+	buf := make([]byte, maxMessageSize)
+
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			if t.isStopped() {
+				t.logger.Info("thread stopped")
+				return nil
+			}
+
+			return fmt.Errorf("udev: failed to read from uevent socket: %v", err)
+		}
+
+		evt, err := parseUevent(buf[:n])
+		if err != nil {
+			t.logger.Debugw("ignoring uevent", "error", err)
+			continue
+		}
+
+		if !evt.isDRMHotplug() {
+			continue
+		}
+
+		t.logger.Debugw("received drm hotplug event",
+			"devpath", evt.DevPath,
+			"seqnum", evt.Env["SEQNUM"],
+		)
+
+		t.bus.Publish(event.New(event.SourceUdev, event.ReasonHotplug))
+	}
+}
+
+// Stop attempts to stop this thread.
+func (t *Thread) Stop() error {
+	t.logger.Infow("thread stopping")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stopped = true
+
+	// Closing the socket is what interrupts a read that's waiting for an event.
+	if t.file != nil {
+		t.file.Close()
+	}
+
+	return nil
+}
+
+// isStopped returns true if this thread has been asked to stop.
+func (t *Thread) isStopped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.stopped
+}
+
+// openSocket opens a netlink socket that receives the kernel's uevents. It's non-blocking, so that
+// reads from the returned file can be interrupted by closing it.
+func openSocket() (*os.File, error) {
+	fd, err := syscall.Socket(
+		syscall.AF_NETLINK,
+		syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK,
+		syscall.NETLINK_KOBJECT_UEVENT,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("udev: failed to open uevent socket: %v", err)
+	}
+
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: kernelGroup,
+	})
+
+	if err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("udev: failed to bind uevent socket: %v", err)
+	}
+
+	return os.NewFile(uintptr(fd), "uevent"), nil
+}
@@ -0,0 +1,67 @@
+// Package udev listens for the kernel's device events, so that i3adc can notice outputs being
+// connected or disconnected even when neither i3 nor X report it promptly.
+package udev
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// udevPrefix starts messages sent by udev, rather than the kernel. They're in a different format.
+const udevPrefix = "libudev\x00"
+
+// uevent is an event from the kernel about a device.
+type uevent struct {
+	// Action is what happened to the device, like "change".
+	Action string
+	// DevPath is the device's path in sysfs, like "/devices/pci0000:00/0000:00:02.0/drm/card0".
+	DevPath string
+	// Env contains the event's variables, like "SUBSYSTEM" and "HOTPLUG".
+	Env map[string]string
+}
+
+// isDRMHotplug returns true if this event is the kernel telling us that outputs may have been
+// connected or disconnected.
+func (e uevent) isDRMHotplug() bool {
+	return e.Action == "change" && e.Env["SUBSYSTEM"] == "drm" && e.Env["HOTPLUG"] == "1"
+}
+
+// parseUevent parses the given message from the kernel's uevent netlink socket. The message is a
+// header, like "change@/devices/...", followed by variables like "SUBSYSTEM=drm", each terminated
+// by a NUL byte.
+func parseUevent(buf []byte) (uevent, error) {
+	var evt uevent
+
+	if bytes.HasPrefix(buf, []byte(udevPrefix)) {
+		return evt, errors.New("udev: message is from udev, not the kernel")
+	}
+
+	fields := bytes.Split(bytes.TrimRight(buf, "\x00"), []byte{0})
+
+	header := string(fields[0])
+	at := strings.IndexByte(header, '@')
+	if at <= 0 {
+		return evt, errors.New("udev: message has an invalid header")
+	}
+
+	evt.Action = header[:at]
+	evt.DevPath = header[at+1:]
+	evt.Env = make(map[string]string, len(fields)-1)
+
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(string(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		evt.Env[kv[0]] = kv[1]
+	}
+
+	// The header and the variables should agree, otherwise something's wrong.
+	if action, ok := evt.Env["ACTION"]; ok && action != evt.Action {
+		return evt, errors.New("udev: message has mismatched actions")
+	}
+
+	return evt, nil
+}
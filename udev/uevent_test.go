@@ -0,0 +1,106 @@
+package udev
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Messages captured from the uevent socket, with their NUL bytes.
+var (
+	drmHotplug = "change@/devices/pci0000:00/0000:00:02.0/drm/card0\x00" +
+		"ACTION=change\x00" +
+		"DEVPATH=/devices/pci0000:00/0000:00:02.0/drm/card0\x00" +
+		"SUBSYSTEM=drm\x00" +
+		"HOTPLUG=1\x00" +
+		"DEVNAME=dri/card0\x00" +
+		"DEVTYPE=drm_minor\x00" +
+		"SEQNUM=4312\x00" +
+		"MAJOR=226\x00" +
+		"MINOR=0\x00"
+
+	drmAdd = "add@/devices/pci0000:00/0000:00:02.0/drm/card0/card0-DP-1\x00" +
+		"ACTION=add\x00" +
+		"DEVPATH=/devices/pci0000:00/0000:00:02.0/drm/card0/card0-DP-1\x00" +
+		"SUBSYSTEM=drm\x00" +
+		"SEQNUM=1904\x00"
+
+	usbAdd = "add@/devices/pci0000:00/0000:00:14.0/usb1/1-2\x00" +
+		"ACTION=add\x00" +
+		"DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-2\x00" +
+		"SUBSYSTEM=usb\x00" +
+		"DEVNAME=bus/usb/001/007\x00" +
+		"DEVTYPE=usb_device\x00" +
+		"SEQNUM=4320\x00"
+
+	powerChange = "change@/devices/LNXSYSTM:00/LNXSYBUS:00/ACPI0003:00/power_supply/AC\x00" +
+		"ACTION=change\x00" +
+		"DEVPATH=/devices/LNXSYSTM:00/LNXSYBUS:00/ACPI0003:00/power_supply/AC\x00" +
+		"SUBSYSTEM=power_supply\x00" +
+		"POWER_SUPPLY_NAME=AC\x00" +
+		"POWER_SUPPLY_ONLINE=1\x00" +
+		"SEQNUM=4330\x00"
+)
+
+func TestParseUevent(t *testing.T) {
+	t.Run("should parse the header and variables", func(t *testing.T) {
+		evt, err := parseUevent([]byte(drmHotplug))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, "change", evt.Action)
+		assert.Equal(t, "/devices/pci0000:00/0000:00:02.0/drm/card0", evt.DevPath)
+		assert.Equal(t, "drm", evt.Env["SUBSYSTEM"])
+		assert.Equal(t, "1", evt.Env["HOTPLUG"])
+		assert.Equal(t, "4312", evt.Env["SEQNUM"])
+		assert.Len(t, evt.Env, 9)
+	})
+
+	t.Run("should keep values that contain an equals sign", func(t *testing.T) {
+		evt, err := parseUevent([]byte("change@/devices/test\x00ACTION=change\x00NAME=a=b\x00"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "a=b", evt.Env["NAME"])
+		}
+	})
+
+	t.Run("should reject messages from udev", func(t *testing.T) {
+		_, err := parseUevent([]byte(udevPrefix + "\xfe\xed\xca\xfe" + strings.Repeat("\x00", 32)))
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject messages without a valid header", func(t *testing.T) {
+		for _, msg := range []string{"", "\x00", "change\x00ACTION=change\x00", "@/devices\x00"} {
+			_, err := parseUevent([]byte(msg))
+			assert.Error(t, err, "expected error for %q", msg)
+		}
+	})
+
+	t.Run("should reject messages with mismatched actions", func(t *testing.T) {
+		_, err := parseUevent([]byte("change@/devices/test\x00ACTION=add\x00"))
+		assert.Error(t, err)
+	})
+}
+
+func TestUeventIsDRMHotplug(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      string
+		expected bool
+	}{
+		{name: "drm hotplug", msg: drmHotplug, expected: true},
+		{name: "drm connector added", msg: drmAdd, expected: false},
+		{name: "usb device added", msg: usbAdd, expected: false},
+		{name: "power supply change", msg: powerChange, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run("should recognise "+test.name, func(t *testing.T) {
+			evt, err := parseUevent([]byte(test.msg))
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.expected, evt.isDRMHotplug())
+			}
+		})
+	}
+}
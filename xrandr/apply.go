@@ -0,0 +1,77 @@
+package xrandr
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/seeruk/i3adc/logging"
+)
+
+// ApplyLayout runs the xrandr commands required to make the given layout the active configuration.
+// Outputs are configured one at a time, in the order they appear in the layout.
+func (c *Client) ApplyLayout(layout []Output) error {
+	return applyLayout(c.logger, layout)
+}
+
+// applyLayout runs the xrandr commands required to make the given layout the active configuration.
+// Outputs are configured one at a time, in the order they appear in the layout.
+func applyLayout(logger logging.Logger, layout []Output) error {
+	for _, output := range layout {
+		args := outputArgs(output)
+
+		logger.Debugw("running command", "command", "xrandr", "args", args)
+
+		// Attempt to run xrandr command...
+		err := exec.Command("xrandr", args...).Run()
+		if err != nil {
+			return fmt.Errorf("xrandr: failed to configure output %q: %v", output.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// outputArgs returns the xrandr command arguments needed to configure the given output.
+func outputArgs(output Output) []string {
+	if !output.IsConnected || !output.IsEnabled {
+		// We have to turn off ones that shouldn't be there!
+		return []string{
+			"--output", output.Name,
+			"--off",
+		}
+	}
+
+	args := []string{
+		"--output", output.Name,
+		"--pos", fmt.Sprintf("%dx%d", output.OffsetX, output.OffsetY),
+		"--rotate", output.Rotation.String(),
+		"--reflect", output.Reflection.String(),
+	}
+
+	// Prefer the mode name, as the width and height we read from X are those of the CRTC, which
+	// will be swapped for rotated outputs.
+	switch {
+	case output.ModeName != "":
+		args = append(args, "--mode", output.ModeName)
+	case output.Width > 0 && output.Height > 0:
+		args = append(args, "--mode", fmt.Sprintf("%dx%d", output.Width, output.Height))
+	default:
+		args = append(args, "--auto")
+	}
+
+	// Several modes may share a name, so the rate picks between them. It means nothing without a
+	// mode though.
+	if output.Rate > 0 && output.ModeName != "" {
+		args = append(args, "--rate", fmt.Sprintf("%.2f", output.Rate))
+	}
+
+	if output.Scale > 0 {
+		args = append(args, "--scale", fmt.Sprintf("%gx%g", output.Scale, output.Scale))
+	}
+
+	if output.IsPrimary {
+		args = append(args, "--primary")
+	}
+
+	return args
+}
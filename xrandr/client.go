@@ -1,19 +1,27 @@
 package xrandr
 
 import (
+	"math"
+
 	"github.com/BurntSushi/xgb"
 	"github.com/BurntSushi/xgb/randr"
 	"github.com/BurntSushi/xgb/xproto"
+	"github.com/seeruk/i3adc/logging"
 )
 
 // Client is an xrandr client, using the X binary protocol for communication via XGB.
 type Client struct {
-	conn *xgb.Conn
-	root xproto.Window
+	conn   *xgb.Conn
+	root   xproto.Window
+	drm    *DRMReader
+	logger logging.Logger
 }
 
-// NewClient returns a new instance of the xrandr client.
-func NewClient() (*Client, error) {
+// NewClient returns a new instance of the xrandr client. If a DRM reader is given, the outputs X
+// reports are cross-checked with the kernel's DRM connectors.
+func NewClient(drm *DRMReader, logger logging.Logger) (*Client, error) {
+	logger = logger.With("module", "xrandr/client")
+
 	conn, err := xgb.NewConn()
 	if err != nil {
 		return nil, err
@@ -29,11 +37,15 @@ func NewClient() (*Client, error) {
 	root := xproto.Setup(conn).DefaultScreen(conn).Root
 
 	return &Client{
-		conn: conn,
-		root: root,
+		conn:   conn,
+		root:   root,
+		drm:    drm,
+		logger: logger,
 	}, nil
 }
 
+// GetOutputs returns every output, as X reports them, with any EDIDs that X is missing filled in
+// from the kernel's DRM connectors.
 func (c *Client) GetOutputs() ([]Output, error) {
 	var outputs []Output
 
@@ -89,14 +101,22 @@ func (c *Client) GetOutputs() ([]Output, error) {
 			output.OffsetX = int(crtcInfo.X)
 			output.OffsetY = int(crtcInfo.Y)
 
-			// Assign mode name, so we can set it again later via xrandr command.
+			// Assign mode name and rate, so we can set it again later via xrandr command.
 			for _, modeID := range info.Modes {
 				mode := modes[uint32(modeID)]
 				if uint(crtcInfo.Mode) == mode.ID {
 					output.ModeName = mode.Name
+					output.Rate = mode.Rate
 				}
 			}
 
+			// Scale is part of the CRTC's transform, which is only set if the output is transformed
+			// somehow, so it's okay for this one to error too.
+			transform, err := randr.GetCrtcTransform(c.conn, info.Crtc).Reply()
+			if err == nil {
+				output.Scale = scaleFromTransform(transform)
+			}
+
 			// Rotation:
 			switch {
 			case (crtcInfo.Rotation & randr.RotationRotate0) != 0:
@@ -150,7 +170,34 @@ func (c *Client) GetOutputs() ([]Output, error) {
 		outputs = append(outputs, output)
 	}
 
-	return outputs, nil
+	return c.crossCheck(outputs), nil
+}
+
+// crossCheck fills in any EDIDs that X is missing from the kernel's DRM connectors, and logs
+// anything else that X and the kernel disagree about. They often disagree for a moment right after
+// hotplug, so that's not worth a warning. Failing to read the connectors doesn't stop
+// the outputs from being used, so it's only logged.
+func (c *Client) crossCheck(outputs []Output) []Output {
+	if c.drm == nil {
+		return outputs
+	}
+
+	connectors, err := c.drm.Connectors()
+	if err != nil {
+		c.logger.Warnw("failed to read drm connectors", "error", err)
+		return outputs
+	}
+
+	outputs, mismatches := crossCheck(outputs, connectors)
+	for _, mismatch := range mismatches {
+		c.logger.Infow("kernel and X disagree about output",
+			"output", mismatch.Output,
+			"connector", mismatch.Connector,
+			"problem", mismatch.Problem,
+		)
+	}
+
+	return outputs
 }
 
 // prepareModes gets all of the modes for the current screen in a format that's more useful to us.
@@ -164,6 +211,7 @@ func prepareModes(resources *randr.GetScreenResourcesReply) map[uint32]Mode {
 			Name:        string(resources.Names[nameOffset : nameOffset+int(xmode.NameLen)]),
 			Width:       uint(xmode.Width),
 			Height:      uint(xmode.Height),
+			Rate:        modeRate(xmode),
 			IsPreferred: false,
 		}
 
@@ -172,3 +220,41 @@ func prepareModes(resources *randr.GetScreenResourcesReply) map[uint32]Mode {
 
 	return modes
 }
+
+// modeRate calculates the refresh rate of the given mode, in Hz, rounded to 2 decimal places like
+// the xrandr command shows it.
+func modeRate(xmode randr.ModeInfo) float64 {
+	vtotal := float64(xmode.Vtotal)
+
+	if xmode.ModeFlags&randr.ModeFlagDoubleScan != 0 {
+		vtotal *= 2
+	}
+
+	if xmode.ModeFlags&randr.ModeFlagInterlace != 0 {
+		vtotal /= 2
+	}
+
+	if xmode.Htotal == 0 || vtotal == 0 {
+		return 0
+	}
+
+	rate := float64(xmode.DotClock) / (float64(xmode.Htotal) * vtotal)
+
+	return math.Round(rate*100) / 100
+}
+
+// scaleFromTransform returns the scale of a CRTC, from it's current transform. If the transform
+// isn't a simple, uniform scale, then 0 is returned, meaning the scale is unknown.
+func scaleFromTransform(transform *randr.GetCrtcTransformReply) float64 {
+	if !transform.HasTransforms {
+		return 1
+	}
+
+	matrix := transform.CurrentTransform
+	if matrix.Matrix11 != matrix.Matrix22 || matrix.Matrix12 != 0 || matrix.Matrix21 != 0 {
+		return 0
+	}
+
+	// The matrix is made of 16.16 fixed point numbers.
+	return math.Round(float64(matrix.Matrix11)/65536*100) / 100
+}
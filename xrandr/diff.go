@@ -0,0 +1,124 @@
+package xrandr
+
+import (
+	"fmt"
+)
+
+// DiffLayouts returns a human-readable list of the differences between two layouts. Outputs are
+// matched by name. If the layouts are the same, an empty slice is returned.
+func DiffLayouts(from, to []Output) []string {
+	var diff []string
+
+	fromByName := make(map[string]Output, len(from))
+	for _, output := range from {
+		fromByName[output.Name] = output
+	}
+
+	toByName := make(map[string]Output, len(to))
+	for _, output := range to {
+		toByName[output.Name] = output
+	}
+
+	for _, output := range from {
+		if _, ok := toByName[output.Name]; !ok {
+			diff = append(diff, fmt.Sprintf("%s: removed", output.Name))
+		}
+	}
+
+	for _, output := range to {
+		old, ok := fromByName[output.Name]
+		if !ok {
+			diff = append(diff, fmt.Sprintf("%s: added (%s)", output.Name, DescribeOutput(output)))
+			continue
+		}
+
+		diff = append(diff, diffOutputs(old, output)...)
+	}
+
+	return diff
+}
+
+// diffOutputs returns a human-readable list of the differences between two versions of an output.
+func diffOutputs(from, to Output) []string {
+	var diff []string
+
+	change := func(field string, was, now interface{}) {
+		if was != now {
+			diff = append(diff, fmt.Sprintf("%s: %s %v -> %v", to.Name, field, was, now))
+		}
+	}
+
+	change("connected", from.IsConnected, to.IsConnected)
+	change("enabled", from.IsEnabled, to.IsEnabled)
+
+	// The rest of the configuration is meaningless if the output is off on both sides.
+	if !from.IsEnabled && !to.IsEnabled {
+		return diff
+	}
+
+	change("primary", from.IsPrimary, to.IsPrimary)
+	change("mode", modeString(from), modeString(to))
+	change("position", positionString(from), positionString(to))
+	change("rotation", from.Rotation, to.Rotation)
+	change("reflection", from.Reflection, to.Reflection)
+	change("scale", scaleString(from), scaleString(to))
+
+	return diff
+}
+
+// DescribeOutput returns a short human-readable description of an output's configuration.
+func DescribeOutput(output Output) string {
+	switch {
+	case !output.IsConnected:
+		return "disconnected"
+	case !output.IsEnabled:
+		return "off"
+	}
+
+	desc := fmt.Sprintf("%s at %s", modeString(output), positionString(output))
+	if output.Rotation != RotationNormal {
+		desc += ", rotated " + output.Rotation.String()
+	}
+
+	if output.Reflection != ReflectionNormal {
+		desc += ", reflected " + output.Reflection.String()
+	}
+
+	if output.Scale > 0 && output.Scale != 1 {
+		desc += ", scaled " + scaleString(output)
+	}
+
+	if output.IsPrimary {
+		desc += ", primary"
+	}
+
+	return desc
+}
+
+// modeString returns the mode of the given output as a string, including it's rate if known.
+func modeString(output Output) string {
+	mode := output.ModeName
+	if mode == "" {
+		mode = fmt.Sprintf("%dx%d", output.Width, output.Height)
+	}
+
+	if output.Rate > 0 {
+		mode += fmt.Sprintf("@%.2f", output.Rate)
+	}
+
+	return mode
+}
+
+// scaleString returns the scale of the given output as a string. An unknown scale is treated as 1.
+func scaleString(output Output) string {
+	if output.Scale <= 0 {
+		return "1x"
+	}
+
+	return fmt.Sprintf("%gx", output.Scale)
+}
+
+// positionString returns the position of the given output as a string.
+func positionString(output Output) string {
+	return fmt.Sprintf("%d,%d", output.OffsetX, output.OffsetY)
+}
@@ -0,0 +1,34 @@
+package xrandr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffLayouts(t *testing.T) {
+	laptop := testOutput("eDP-1", "laptop", true)
+	laptop.IsEnabled = true
+	laptop.ModeName = "1920x1080"
+
+	dell := testOutput("DP-1", "dell", true)
+
+	t.Run("should return nothing for identical layouts", func(t *testing.T) {
+		assert.Empty(t, DiffLayouts([]Output{laptop, dell}, []Output{laptop, dell}))
+	})
+
+	t.Run("should describe changed, added and removed outputs", func(t *testing.T) {
+		moved := laptop
+		moved.OffsetX = 1920
+		moved.Rotation = RotationLeft
+
+		diff := DiffLayouts([]Output{laptop, dell}, []Output{moved, testOutput("HDMI-1", "", false)})
+
+		assert.Equal(t, []string{
+			"DP-1: removed",
+			"eDP-1: position 0,0 -> 1920,0",
+			"eDP-1: rotation normal -> left",
+			"HDMI-1: added (disconnected)",
+		}, diff)
+	})
+}
@@ -0,0 +1,85 @@
+package xrandr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// Display descriptor types, found in the descriptor blocks of an EDID.
+const (
+	edidDescriptorSerial = 0xFF
+	edidDescriptorName   = 0xFC
+)
+
+var (
+	// ErrInvalidEDID is returned when an EDID can't be parsed.
+	ErrInvalidEDID = errors.New("xrandr: invalid EDID")
+
+	// edidHeader is the fixed header that every EDID starts with.
+	edidHeader = []byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00}
+)
+
+// EDID contains the identifying information from a monitor's EDID (Extended Display Identification
+// Data), which is read from the output's "EDID" property.
+type EDID struct {
+	// Manufacturer is the three letter PNP ID of the manufacturer, like "DEL".
+	Manufacturer string `json:"manufacturer"`
+	// ProductCode is the manufacturer's product code for the monitor.
+	ProductCode uint16 `json:"product_code"`
+	// SerialNumber is the numeric serial number. It's often 0, with the serial in Serial instead.
+	SerialNumber uint32 `json:"serial_number"`
+	// Model is the monitor's name, like "DELL U2715H", if it has one.
+	Model string `json:"model"`
+	// Serial is the monitor's serial number as text, if it has one.
+	Serial string `json:"serial"`
+}
+
+// ParseEDID parses the base block of the given EDID.
+func ParseEDID(bs []byte) (EDID, error) {
+	var edid EDID
+
+	if len(bs) < 128 || !bytes.Equal(bs[:8], edidHeader) {
+		return edid, ErrInvalidEDID
+	}
+
+	// The manufacturer ID is 3 letters packed into 5 bits each, where 1 is "A".
+	id := binary.BigEndian.Uint16(bs[8:10])
+	edid.Manufacturer = string([]byte{
+		byte(id>>10&0x1F) + 'A' - 1,
+		byte(id>>5&0x1F) + 'A' - 1,
+		byte(id&0x1F) + 'A' - 1,
+	})
+
+	edid.ProductCode = binary.LittleEndian.Uint16(bs[10:12])
+	edid.SerialNumber = binary.LittleEndian.Uint32(bs[12:16])
+
+	// There are 4 descriptor blocks, which either describe a timing, or, if they start with 0, hold
+	// some other information about the display, like it's name.
+	for offset := 54; offset < 126; offset += 18 {
+		descriptor := bs[offset : offset+18]
+		if descriptor[0] != 0 || descriptor[1] != 0 {
+			continue
+		}
+
+		switch descriptor[3] {
+		case edidDescriptorSerial:
+			edid.Serial = edidString(descriptor[5:])
+		case edidDescriptorName:
+			edid.Model = edidString(descriptor[5:])
+		}
+	}
+
+	return edid, nil
+}
+
+// edidString returns the text in an EDID display descriptor, which ends with a newline if it's
+// shorter than the descriptor, and is then padded with spaces.
+func edidString(bs []byte) string {
+	if i := bytes.IndexByte(bs, '\n'); i >= 0 {
+		bs = bs[:i]
+	}
+
+	return strings.TrimSpace(string(bs))
+}
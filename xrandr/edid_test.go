@@ -0,0 +1,74 @@
+package xrandr
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEDID(t *testing.T) {
+	t.Run("should parse the identifying information", func(t *testing.T) {
+		edid, err := ParseEDID(testEDID("DEL", 0xA0C4, 12345, "DELL U2715H", "ABC123"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, EDID{
+			Manufacturer: "DEL",
+			ProductCode:  0xA0C4,
+			SerialNumber: 12345,
+			Model:        "DELL U2715H",
+			Serial:       "ABC123",
+		}, edid)
+	})
+
+	t.Run("should leave out descriptors that aren't present", func(t *testing.T) {
+		edid, err := ParseEDID(testEDID("LGD", 1, 0, "", ""))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "LGD", edid.Manufacturer)
+		assert.Equal(t, "", edid.Model)
+		assert.Equal(t, "", edid.Serial)
+	})
+
+	t.Run("should reject invalid EDIDs", func(t *testing.T) {
+		_, err := ParseEDID([]byte("laptop"))
+		assert.Equal(t, ErrInvalidEDID, err)
+
+		bs := testEDID("DEL", 1, 0, "", "")
+		bs[0] = 1
+
+		_, err = ParseEDID(bs)
+		assert.Equal(t, ErrInvalidEDID, err)
+	})
+}
+
+// testEDID returns the base block of an EDID containing the given information. Empty model names
+// and serials are left out.
+func testEDID(manufacturer string, productCode uint16, serialNumber uint32, model, serial string) []byte {
+	bs := make([]byte, 128)
+	copy(bs, edidHeader)
+
+	id := uint16(manufacturer[0]-'A'+1)<<10 | uint16(manufacturer[1]-'A'+1)<<5 | uint16(manufacturer[2]-'A'+1)
+	binary.BigEndian.PutUint16(bs[8:], id)
+	binary.LittleEndian.PutUint16(bs[10:], productCode)
+	binary.LittleEndian.PutUint32(bs[12:], serialNumber)
+
+	// The first descriptor is usually the preferred timing, which these tests don't care about.
+	bs[54] = 1
+
+	descriptor := func(offset int, kind byte, text string) {
+		bs[offset+3] = kind
+		copy(bs[offset+5:offset+18], "             ")
+		copy(bs[offset+5:], text+"\n")
+	}
+
+	if model != "" {
+		descriptor(72, edidDescriptorName, model)
+	}
+
+	if serial != "" {
+		descriptor(90, edidDescriptorSerial, serial)
+	}
+
+	return bs
+}
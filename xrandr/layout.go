@@ -0,0 +1,302 @@
+package xrandr
+
+import (
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/seeruk/i3adc/config"
+)
+
+// internalPrefixes are the prefixes of the names of outputs that are built in to a device, like a
+// laptop's display.
+var internalPrefixes = []string{"eDP", "LVDS", "DSI"}
+
+// outputID returns an identifier for a single output, made from the same properties that are used
+// to produce the hash of a set of outputs.
+func outputID(output Output) string {
+	return output.Name + ":" + hex.EncodeToString(output.Properties["EDID"])
+}
+
+// connectedOutputIDs returns the set of identifiers of the connected outputs in the given layout.
+func connectedOutputIDs(outputs []Output) map[string]bool {
+	ids := make(map[string]bool)
+	for _, output := range outputs {
+		if output.IsConnected {
+			ids[outputID(output)] = true
+		}
+	}
+
+	return ids
+}
+
+// findClosestLayout looks through the given saved layouts for the one that is the closest match for
+// the given current outputs. A saved layout is considered a match if it's connected outputs are
+// either a subset or a superset of the currently connected outputs. Subsets are preferred over
+// supersets, and of those, the layout sharing the most outputs with the current outputs wins,
+// followed by the one with the fewest outputs that aren't currently connected. If nothing matches,
+// an empty hash is returned.
+func findClosestLayout(current []Output, saved map[string][]Output) (string, []Output) {
+	currentIDs := connectedOutputIDs(current)
+
+	// Iterate in a stable order, so that ties are always broken the same way.
+	hashes := make([]string, 0, len(saved))
+	for hash := range saved {
+		hashes = append(hashes, hash)
+	}
+
+	sort.Strings(hashes)
+
+	var bestHash string
+	var bestIsSubset bool
+	var bestShared, bestExtra int
+
+	for _, hash := range hashes {
+		savedIDs := connectedOutputIDs(saved[hash])
+
+		var shared int
+		for id := range savedIDs {
+			if currentIDs[id] {
+				shared++
+			}
+		}
+
+		extra := len(savedIDs) - shared
+		isSubset := extra == 0
+		isSuperset := shared == len(currentIDs)
+
+		if shared == 0 || (!isSubset && !isSuperset) {
+			continue
+		}
+
+		isBetter := isSubset && !bestIsSubset ||
+			isSubset == bestIsSubset && (shared > bestShared || shared == bestShared && extra < bestExtra)
+
+		if bestHash == "" || isBetter {
+			bestHash = hash
+			bestIsSubset = isSubset
+			bestShared = shared
+			bestExtra = extra
+		}
+	}
+
+	if bestHash == "" {
+		return "", nil
+	}
+
+	return bestHash, saved[bestHash]
+}
+
+// planLayout produces the layout that should be applied for the given current outputs, based on
+// the given saved layout (which may be nil). Outputs that are known to the saved layout will keep
+// their saved configuration, disconnected outputs are turned off, and any other connected outputs
+// are enabled at their preferred mode and rate, unscaled, and placed in a row to the right of the known outputs. If
+// none of the known outputs are primary, one of the new outputs is made primary, chosen using the
+// given primary policy.
+func planLayout(current []Output, saved []Output, primary string) []Output {
+	savedByID := make(map[string]Output)
+	for _, output := range saved {
+		if output.IsConnected {
+			savedByID[outputID(output)] = output
+		}
+	}
+
+	planned := make([]Output, 0, len(current))
+	known := make([]int, 0, len(current))
+
+	for _, output := range current {
+		savedOutput, ok := savedByID[outputID(output)]
+		if ok && output.IsConnected {
+			output.IsEnabled = savedOutput.IsEnabled
+			output.IsPrimary = savedOutput.IsPrimary
+			output.ModeName = savedOutput.ModeName
+			output.Width = savedOutput.Width
+			output.Height = savedOutput.Height
+			output.Rate = savedOutput.Rate
+			output.Scale = savedOutput.Scale
+			output.OffsetX = savedOutput.OffsetX
+			output.OffsetY = savedOutput.OffsetY
+			output.Rotation = savedOutput.Rotation
+			output.Reflection = savedOutput.Reflection
+
+			if output.IsEnabled {
+				known = append(known, len(planned))
+			}
+		}
+
+		planned = append(planned, output)
+	}
+
+	// If some of the saved outputs are no longer connected, the remaining ones may no longer start
+	// at the origin, so they're moved back to it, keeping their positions relative to each other.
+	minX, minY, maxX := bounds(planned, known)
+	for _, i := range known {
+		planned[i].OffsetX -= minX
+		planned[i].OffsetY -= minY
+	}
+
+	nextX := maxX - minX
+	hasPrimary := false
+	for _, i := range known {
+		hasPrimary = hasPrimary || planned[i].IsPrimary
+	}
+
+	var added []int
+
+	for i, output := range planned {
+		if _, ok := savedByID[outputID(output)]; ok && output.IsConnected {
+			continue
+		}
+
+		if !output.IsConnected {
+			planned[i].IsEnabled = false
+			planned[i].IsPrimary = false
+			continue
+		}
+
+		planned[i].IsEnabled = true
+		planned[i].IsPrimary = false
+		planned[i].OffsetX = nextX
+		planned[i].OffsetY = 0
+		planned[i].Rotation = RotationNormal
+		planned[i].Reflection = ReflectionNormal
+		planned[i].ModeName = ""
+		planned[i].Width = 0
+		planned[i].Height = 0
+		planned[i].Rate = 0
+		planned[i].Scale = 1
+
+		for _, mode := range output.Modes {
+			if mode.IsPreferred {
+				planned[i].ModeName = mode.Name
+				planned[i].Width = mode.Width
+				planned[i].Height = mode.Height
+				planned[i].Rate = mode.Rate
+			}
+		}
+
+		nextX += int(planned[i].Width)
+		added = append(added, i)
+	}
+
+	if !hasPrimary && len(added) > 0 {
+		planned[choosePrimary(planned, added, primary)].IsPrimary = true
+	}
+
+	return planned
+}
+
+// choosePrimary returns which of the outputs at the given indexes in the given outputs should be
+// made primary, according to the given policy. If nothing fits the policy, the first one is used.
+func choosePrimary(outputs []Output, indexes []int, policy string) int {
+	switch policy {
+	case config.PrimaryLargest:
+		largest := indexes[0]
+		for _, i := range indexes[1:] {
+			if outputs[i].Width*outputs[i].Height > outputs[largest].Width*outputs[largest].Height {
+				largest = i
+			}
+		}
+
+		return largest
+	case config.PrimaryInternal, config.PrimaryExternal:
+		for _, i := range indexes {
+			if isInternal(outputs[i]) == (policy == config.PrimaryInternal) {
+				return i
+			}
+		}
+	}
+
+	return indexes[0]
+}
+
+// isInternal returns true if the given output looks like it's built in to the device, like a
+// laptop's display.
+func isInternal(output Output) bool {
+	for _, prefix := range internalPrefixes {
+		if strings.HasPrefix(output.Name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bounds returns the minimum X and Y offsets, and the right-most edge of the outputs at the given
+// indexes in the given outputs. If there are no indexes, all values will be 0.
+func bounds(outputs []Output, indexes []int) (minX, minY, maxX int) {
+	for n, i := range indexes {
+		output := outputs[i]
+
+		// Rotated outputs report their rotated size, so their width is already correct here.
+		right := output.OffsetX + int(output.Width)
+
+		if n == 0 || output.OffsetX < minX {
+			minX = output.OffsetX
+		}
+
+		if n == 0 || output.OffsetY < minY {
+			minY = output.OffsetY
+		}
+
+		if n == 0 || right > maxX {
+			maxX = right
+		}
+	}
+
+	return minX, minY, maxX
+}
+
+// matchesLayout returns true if the given actual outputs are configured as the given expected
+// layout says they should be. Anything the expected layout doesn't know, like a rate or scale of 0,
+// is ignored. Outputs are matched by name.
+func matchesLayout(expected, actual []Output) bool {
+	actualByName := make(map[string]Output, len(actual))
+	for _, output := range actual {
+		actualByName[output.Name] = output
+	}
+
+	for _, want := range expected {
+		got, ok := actualByName[want.Name]
+		if !ok || want.IsConnected != got.IsConnected {
+			return false
+		}
+
+		wantEnabled := want.IsConnected && want.IsEnabled
+		if wantEnabled != (got.IsConnected && got.IsEnabled) {
+			return false
+		}
+
+		if !wantEnabled {
+			continue
+		}
+
+		switch {
+		case want.IsPrimary != got.IsPrimary:
+		case want.OffsetX != got.OffsetX, want.OffsetY != got.OffsetY:
+		case want.Rotation != got.Rotation, want.Reflection != got.Reflection:
+		case want.ModeName != "" && want.ModeName != got.ModeName:
+		case want.ModeName == "" && want.Width > 0 && (want.Width != got.Width || want.Height != got.Height):
+		case want.Rate > 0 && want.Rate != got.Rate:
+		case want.Scale > 0 && want.Scale != got.Scale:
+		default:
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// ConnectedOutputNames returns the names of the connected outputs in the given layout.
+func ConnectedOutputNames(layout []Output) []string {
+	var names []string
+	for _, output := range layout {
+		if output.IsConnected {
+			names = append(names, output.Name)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,254 @@
+package xrandr
+
+import (
+	"testing"
+
+	"github.com/seeruk/i3adc/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindClosestLayout(t *testing.T) {
+	laptop := testOutput("eDP-1", "laptop", true)
+	dell := testOutput("DP-1", "dell", true)
+	projector := testOutput("HDMI-1", "projector", true)
+	other := testOutput("DP-2", "other", true)
+
+	t.Run("should match the largest saved subset of the current outputs", func(t *testing.T) {
+		saved := map[string][]Output{
+			"a": {laptop},
+			"b": {laptop, dell},
+			"c": {other},
+		}
+
+		hash, layout := findClosestLayout([]Output{laptop, dell, projector}, saved)
+
+		assert.Equal(t, "b", hash)
+		assert.Equal(t, saved["b"], layout)
+	})
+
+	t.Run("should match a saved superset of the current outputs", func(t *testing.T) {
+		saved := map[string][]Output{
+			"a": {laptop, dell, projector},
+			"b": {laptop, other},
+		}
+
+		hash, _ := findClosestLayout([]Output{laptop, dell}, saved)
+
+		assert.Equal(t, "a", hash)
+	})
+
+	t.Run("should prefer a saved subset to a saved superset", func(t *testing.T) {
+		saved := map[string][]Output{
+			"a": {laptop, dell},
+			"b": {laptop, dell, projector, other},
+		}
+
+		hash, _ := findClosestLayout([]Output{laptop, dell, projector}, saved)
+
+		assert.Equal(t, "a", hash)
+	})
+
+	t.Run("should prefer the layout with the fewest extra outputs", func(t *testing.T) {
+		saved := map[string][]Output{
+			"a": {laptop, dell, projector, other},
+			"b": {laptop, dell, projector},
+		}
+
+		hash, _ := findClosestLayout([]Output{laptop, dell}, saved)
+
+		assert.Equal(t, "b", hash)
+	})
+
+	t.Run("should not match layouts that only partially overlap", func(t *testing.T) {
+		saved := map[string][]Output{
+			"a": {laptop, other},
+		}
+
+		hash, layout := findClosestLayout([]Output{laptop, dell}, saved)
+
+		assert.Equal(t, "", hash)
+		assert.Nil(t, layout)
+	})
+
+	t.Run("should ignore disconnected outputs", func(t *testing.T) {
+		saved := map[string][]Output{
+			"a": {testOutput("eDP-1", "laptop", false)},
+		}
+
+		hash, _ := findClosestLayout([]Output{laptop}, saved)
+
+		assert.Equal(t, "", hash)
+	})
+}
+
+func TestPlanLayout(t *testing.T) {
+	t.Run("should place all outputs in a row when there is no saved layout", func(t *testing.T) {
+		current := []Output{
+			testOutput("eDP-1", "laptop", true),
+			testOutput("DP-1", "dell", true),
+			testOutput("HDMI-1", "", false),
+		}
+
+		planned := planLayout(current, nil, config.PrimaryFirst)
+
+		assert.True(t, planned[0].IsEnabled)
+		assert.True(t, planned[0].IsPrimary)
+		assert.Equal(t, 0, planned[0].OffsetX)
+		assert.Equal(t, "1920x1080", planned[0].ModeName)
+		assert.True(t, planned[1].IsEnabled)
+		assert.False(t, planned[1].IsPrimary)
+		assert.Equal(t, 1920, planned[1].OffsetX)
+		assert.False(t, planned[2].IsEnabled)
+	})
+
+	t.Run("should keep known outputs and place new outputs to their right", func(t *testing.T) {
+		laptop := testOutput("eDP-1", "laptop", true)
+		dell := testOutput("DP-1", "dell", true)
+
+		savedLaptop := laptop
+		savedLaptop.IsEnabled = false
+
+		savedDell := dell
+		savedDell.IsEnabled = true
+		savedDell.IsPrimary = true
+		savedDell.ModeName = "1080x1920"
+		savedDell.Width = 1080
+		savedDell.Height = 1920
+		savedDell.OffsetX = 500
+		savedDell.Rotation = RotationLeft
+
+		projector := testOutput("HDMI-1", "projector", true)
+
+		planned := planLayout([]Output{laptop, dell, projector}, []Output{savedLaptop, savedDell}, config.PrimaryFirst)
+
+		assert.False(t, planned[0].IsEnabled)
+		assert.True(t, planned[1].IsEnabled)
+		assert.True(t, planned[1].IsPrimary)
+		assert.Equal(t, 0, planned[1].OffsetX)
+		assert.Equal(t, RotationLeft, planned[1].Rotation)
+		assert.True(t, planned[2].IsEnabled)
+		assert.False(t, planned[2].IsPrimary)
+		assert.Equal(t, 1080, planned[2].OffsetX)
+	})
+
+	t.Run("should keep the saved rate and scale of known outputs", func(t *testing.T) {
+		laptop := testOutput("eDP-1", "laptop", true)
+		laptop.IsEnabled = true
+		laptop.ModeName = "2560x1600"
+		laptop.Rate = 60
+		laptop.Scale = 1
+
+		savedLaptop := laptop
+		savedLaptop.Rate = 120
+		savedLaptop.Scale = 0.5
+
+		planned := planLayout([]Output{laptop}, []Output{savedLaptop}, config.PrimaryFirst)
+
+		assert.Equal(t, 120.0, planned[0].Rate)
+		assert.Equal(t, 0.5, planned[0].Scale)
+	})
+
+	t.Run("should use the preferred rate of new outputs, unscaled", func(t *testing.T) {
+		monitor := testOutput("DP-1", "monitor", true)
+		monitor.Modes = []Mode{
+			{ID: 1, Name: "1920x1080", Width: 1920, Height: 1080, Rate: 75},
+			{ID: 2, Name: "1920x1080", Width: 1920, Height: 1080, Rate: 60, IsPreferred: true},
+		}
+		monitor.IsEnabled = true
+		monitor.ModeName = "1920x1080"
+		monitor.Rate = 75
+		monitor.Scale = 0.5
+
+		planned := planLayout([]Output{monitor}, nil, config.PrimaryFirst)
+
+		assert.Equal(t, "1920x1080", planned[0].ModeName)
+		assert.Equal(t, 60.0, planned[0].Rate)
+		assert.Equal(t, 1.0, planned[0].Scale)
+	})
+}
+
+func TestChoosePrimary(t *testing.T) {
+	laptop := testOutput("eDP-1", "laptop", true)
+	dell := testOutput("DP-1", "dell", true)
+	dell.Width, dell.Height = 2560, 1440
+	projector := testOutput("HDMI-1", "projector", true)
+	projector.Width, projector.Height = 1920, 1080
+
+	outputs := []Output{projector, laptop, dell}
+	indexes := []int{0, 1, 2}
+
+	t.Run("should choose the first output", func(t *testing.T) {
+		assert.Equal(t, 0, choosePrimary(outputs, indexes, config.PrimaryFirst))
+	})
+
+	t.Run("should choose the largest output", func(t *testing.T) {
+		assert.Equal(t, 2, choosePrimary(outputs, indexes, config.PrimaryLargest))
+	})
+
+	t.Run("should choose the internal output", func(t *testing.T) {
+		assert.Equal(t, 1, choosePrimary(outputs, indexes, config.PrimaryInternal))
+	})
+
+	t.Run("should choose the first external output", func(t *testing.T) {
+		assert.Equal(t, 0, choosePrimary(outputs, indexes, config.PrimaryExternal))
+	})
+
+	t.Run("should fall back to the first output if none fit the policy", func(t *testing.T) {
+		assert.Equal(t, 0, choosePrimary(outputs, []int{0, 2}, config.PrimaryInternal))
+	})
+}
+
+func TestMatchesLayout(t *testing.T) {
+	laptop := testOutput("eDP-1", "laptop", true)
+	laptop.IsEnabled = true
+	laptop.IsPrimary = true
+	laptop.ModeName = "1920x1080"
+	laptop.Width, laptop.Height = 1920, 1080
+
+	dell := testOutput("DP-1", "dell", true)
+
+	expected := []Output{laptop, dell}
+
+	t.Run("should match outputs configured as expected", func(t *testing.T) {
+		actual := []Output{dell, laptop}
+		actual[1].Rate = 60
+
+		assert.True(t, matchesLayout(expected, actual))
+	})
+
+	t.Run("should not match outputs configured differently", func(t *testing.T) {
+		moved := laptop
+		moved.OffsetX = 100
+
+		assert.False(t, matchesLayout(expected, []Output{moved, dell}))
+	})
+
+	t.Run("should not match if an output is enabled unexpectedly", func(t *testing.T) {
+		enabled := dell
+		enabled.IsEnabled = true
+
+		assert.False(t, matchesLayout(expected, []Output{laptop, enabled}))
+	})
+
+	t.Run("should not match if an output is missing", func(t *testing.T) {
+		assert.False(t, matchesLayout(expected, []Output{laptop}))
+	})
+}
+
+// testOutput returns an output with the given name and EDID, with a single preferred mode.
+func testOutput(name, edid string, connected bool) Output {
+	output := Output{
+		Name:        name,
+		IsConnected: connected,
+		Properties:  Properties{},
+	}
+
+	if connected {
+		output.Properties["EDID"] = []byte(edid)
+		output.Modes = []Mode{
+			{ID: 1, Name: "1920x1080", Width: 1920, Height: 1080, IsPreferred: true},
+		}
+	}
+
+	return output
+}
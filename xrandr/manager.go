@@ -0,0 +1,1135 @@
+package xrandr
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/seeruk/i3adc/config"
+	"github.com/seeruk/i3adc/event"
+	"github.com/seeruk/i3adc/hook"
+	"github.com/seeruk/i3adc/i3"
+	"github.com/seeruk/i3adc/logging"
+)
+
+// ErrNotConnected is returned when trying to apply a layout for outputs that aren't all connected.
+var ErrNotConnected = errors.New("xrandr: layout is not for the connected outputs")
+
+// Status describes the currently connected outputs, and how they relate to the saved layouts.
+type Status struct {
+	Hash          string   `json:"hash"`
+	IsSaved       bool     `json:"is_saved"`
+	IsPaused      bool     `json:"is_paused"`
+	LatestHash    string   `json:"latest_hash"`
+	ActiveProfile string   `json:"active_profile,omitempty"`
+	Outputs       []Output `json:"outputs"`
+}
+
+// LayoutInfo describes a saved layout.
+type LayoutInfo struct {
+	Hash       string         `json:"hash"`
+	IsLatest   bool           `json:"is_latest"`
+	Profiles   []string       `json:"profiles,omitempty"`
+	Revisions  int            `json:"revisions"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	Outputs    []Output       `json:"outputs"`
+	Workspaces []i3.Workspace `json:"workspaces,omitempty"`
+}
+
+// Commands describes the i3 commands set for a layout, or a profile.
+type Commands struct {
+	Hash     string   `json:"hash"`
+	Profile  string   `json:"profile,omitempty"`
+	Commands []string `json:"commands"`
+}
+
+// Display reads and configures the outputs. It's implemented by Client.
+type Display interface {
+	// GetOutputs returns every output, and how it's configured.
+	GetOutputs() ([]Output, error)
+	// ApplyLayout configures the outputs as they are in the given layout.
+	ApplyLayout(layout []Output) error
+}
+
+// WindowManager saves and restores which outputs workspaces are on, and runs commands. It's
+// implemented by i3.Client.
+type WindowManager interface {
+	// Workspaces returns every workspace, and the output it's on.
+	Workspaces() ([]i3.Workspace, error)
+	// Restore moves the given workspaces back to the outputs they were on.
+	Restore(workspaces []i3.Workspace) error
+	// RunCommands runs each of the given commands, returning the number that failed.
+	RunCommands(commands []string) int
+}
+
+// Manager manages the display configuration, and the layouts saved for it. It's used both by the
+// background thread to react to events, and directly by commands. It's safe for concurrent use.
+type Manager struct {
+	mu        sync.Mutex
+	bus       *event.Bus
+	display   Display
+	config    config.Layout
+	hooks     *hook.Runner
+	logger    logging.Logger
+	rules     []config.Rule
+	stabilise config.Stabilise
+	store     *Store
+	i3Client  WindowManager
+	paused    bool
+
+	// event is the event currently being handled, if any, so that it can be correlated with the
+	// hooks that it causes.
+	event event.Event
+
+	// generation counts the layouts the manager has applied, and expected is the last one, if the
+	// outputs haven't been seen to change from it since.
+	generation int
+	expected   *expectation
+}
+
+// expectation is a layout that the manager has applied, used to recognise the events that applying
+// it causes, so that they aren't mistaken for the user changing the layout.
+type expectation struct {
+	generation int
+	hash       string
+	layout     []Output
+}
+
+// NewManager returns a new layout manager instance, that reads and configures outputs through the
+// given display. The given configuration decides how new layouts are created, and which rules are
+// applied to them (and to saved layouts, if enforced). The given i3 client is used to save and
+// restore which outputs i3's workspaces are on, and to run i3 commands, the given hook runner is
+// told about changes to layouts, and the results of events are published to the given bus. The i3
+// client, hook runner, and bus may be nil.
+func NewManager(store *Store, display Display, i3Client WindowManager, hooks *hook.Runner, bus *event.Bus, config config.Config, logger logging.Logger) *Manager {
+	logger = logger.With("module", "xrandr/manager")
+
+	return &Manager{
+		bus:       bus,
+		display:   display,
+		config:    config.Layout,
+		hooks:     hooks,
+		logger:    logger,
+		rules:     config.Rules,
+		stabilise: config.Events.Stabilise,
+		store:     store,
+		i3Client:  i3Client,
+	}
+}
+
+// SetConfig replaces the configuration that decides how new layouts are created, and which rules
+// are applied to them. It takes effect from the next event.
+func (m *Manager) SetConfig(config config.Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.config = config.Layout
+	m.rules = config.Rules
+	m.stabilise = config.Events.Stabilise
+}
+
+// HandleEvent reacts to the given event by reading the current outputs, and then either creating a
+// new layout for them, updating their saved layout, or switching to their saved layout. If the event
+// asks for an action, that's done instead. Events are ignored while the manager is paused, unless
+// the user asked for them.
+func (m *Manager) HandleEvent(evt event.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	defer m.begin(evt)()
+
+	if m.paused && evt.Reason != event.ReasonUserRequest {
+		m.logger.Debugw("event ignored, paused", "source", evt.Source, "reason", evt.Reason)
+		return nil
+	}
+
+	if evt.Action != nil {
+		return m.handleAction(*evt.Action)
+	}
+
+	return m.handleEvent(evt)
+}
+
+// Reload re-evaluates the currently connected outputs as if i3adc was just starting, applying the
+// saved layout for them (or creating one). Unlike HandleEvent, this happens even when paused.
+func (m *Manager) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	evt := event.New(event.SourceControl, event.ReasonUserRequest)
+	defer m.begin(evt)()
+
+	m.logger.Info("reloading")
+
+	return m.handleEvent(evt)
+}
+
+// Pause stops the manager from reacting to events until Resume is called. Commands still work.
+func (m *Manager) Pause() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.logger.Info("paused")
+	m.paused = true
+}
+
+// Resume allows the manager to react to events again after being paused.
+func (m *Manager) Resume() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.logger.Info("resumed")
+	m.paused = false
+}
+
+// Save saves the current configuration as the layout for the currently connected outputs, even if
+// the manager is paused. If a profile name is given, the layout is also saved as that profile. The
+// hash of the saved layout is returned.
+func (m *Manager) Save(profile string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	currentLayout, err := m.display.GetOutputs()
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := calculateHashForOutputs(currentLayout)
+	if err != nil {
+		return "", err
+	}
+
+	m.logger.Infow("saving current configuration", "hash", hash)
+
+	err = m.saveLayout(hash, currentLayout)
+	if err != nil || profile == "" {
+		return hash, err
+	}
+
+	_, err = m.saveProfile(profile, hash)
+
+	return hash, err
+}
+
+// begin marks the given event as the one being handled, so that it's ID is included in everything
+// that's logged, and passed to any hooks that are run, until the returned function is called. The
+// manager must be locked until then.
+func (m *Manager) begin(evt event.Event) func() {
+	logger := m.logger
+
+	m.event = evt
+	m.logger = logger.With("event_id", evt.ID)
+
+	return func() {
+		m.event = event.Event{}
+		m.logger = logger
+	}
+}
+
+// readStableOutputs reads the outputs once they've stabilised, logging what had to be waited for.
+// If they don't stabilise in time, they're used as they are.
+func (m *Manager) readStableOutputs() ([]Output, error) {
+	start := time.Now()
+	interval := time.Duration(m.stabilise.Interval)
+	timeout := time.Duration(m.stabilise.Timeout)
+
+	result, err := stabiliseOutputs(m.display.GetOutputs, interval, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	waited := time.Since(start).String()
+
+	switch {
+	case len(result.Incomplete) > 0:
+		m.logger.Debugw("outputs stopped changing, using them without what they're missing",
+			"waited", waited,
+			"reads", result.Reads,
+			"missing", result.Incomplete,
+		)
+	case result.TimedOut:
+		m.logger.Warnw("outputs didn't stabilise in time, using them as they are",
+			"waited", waited,
+			"reads", result.Reads,
+			"waited_for", result.WaitedFor,
+		)
+	case len(result.WaitedFor) > 0:
+		m.logger.Infow("waited for outputs to stabilise",
+			"waited", waited,
+			"reads", result.Reads,
+			"waited_for", result.WaitedFor,
+		)
+	default:
+		m.logger.Debugw("outputs are stable", "reads", result.Reads)
+	}
+
+	return result.Outputs, nil
+}
+
+// handleAction does the given action, asked for by an event. The manager must be locked when this
+// is called.
+func (m *Manager) handleAction(action event.Action) error {
+	switch action.Type {
+	case event.ActionApply:
+		return m.apply(action.Ref)
+	default:
+		return fmt.Errorf("xrandr: unknown action: %q", action.Type)
+	}
+}
+
+// handleEvent does the work of HandleEvent. The manager must be locked when this is called.
+func (m *Manager) handleEvent(evt event.Event) error {
+	m.logger.Debugw("event occurred", "source", evt.Source, "reason", evt.Reason, "time", evt.Time)
+
+	currentLayout, err := m.readStableOutputs()
+	if err != nil {
+		return err
+	}
+
+	hash, err := calculateHashForOutputs(currentLayout)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Debugw("calculated hash", "hash", hash)
+
+	latestHash, err := m.store.LatestHash()
+	if err != nil {
+		return err
+	}
+
+	m.logger.Debugw("latest hash", "hash", latestHash)
+
+	savedLayout, err := m.store.Layout(hash)
+	if err != nil {
+		return err
+	}
+
+	// For some events, the saved layout is applied, rather than the current outputs saved.
+	reapply := isReapply(evt.Reason)
+
+	// Once the outputs change to something other than what was applied, any further events can't
+	// be caused by applying it.
+	isEcho := m.expected != nil && m.expected.hash == hash && matchesLayout(m.expected.layout, currentLayout)
+	if !isEcho {
+		m.expected = nil
+	}
+
+	switch {
+	case savedLayout == nil:
+		// If we haven't got a layout stored for this hash, we look for the closest saved layout
+		// instead, i.e. one for a subset or superset of the connected outputs (unless configured
+		// not to). The outputs that layout knows about are configured as they were, and any others
+		// are enabled at their preferred mode. The user can then set their configuration
+		// themselves to update the saved configuration. This is a new layout.
+		baseHash, baseLayout, err := m.findBaseLayout(currentLayout)
+		if err != nil {
+			return err
+		}
+
+		if baseHash != "" {
+			m.logger.Infow("creating a new configuration from closest match", "hash", hash, "base_hash", baseHash)
+		} else {
+			m.logger.Infow("creating a new configuration", "hash", hash)
+		}
+
+		planned := planLayout(currentLayout, baseLayout, m.config.Primary)
+
+		_, err = m.applyLayout(hash, "", applyRules(m.logger, planned, m.rules, false))
+		if err != nil {
+			return err
+		}
+
+		// Re-fetch layout, so our changes are applied to our in-memory representation.
+		newLayout, err := m.display.GetOutputs()
+		if err != nil {
+			return err
+		}
+
+		err = m.saveLayout(hash, newLayout)
+		if err != nil {
+			return err
+		}
+
+		m.hooks.Start(m.newHookEvent(config.HookLayoutCreated, hash, latestHash, currentLayout, newLayout))
+
+		return nil
+	case !reapply && hash == latestHash && isEcho:
+		// Applying a layout causes events of it's own. The outputs are configured as they were
+		// applied, so nothing has changed, and there's nothing to save.
+		m.logger.Debugw("ignoring event caused by applying layout", "hash", hash, "generation", m.expected.generation)
+
+		return nil
+	case !reapply && hash == latestHash:
+		// If the hash is the same, we want to update the existing layout at that hash. Either this
+		// output configuration has been used before, or the user has just updated it. Technically,
+		// all we need to do is that update here...
+		m.logger.Infow("updating an existing configuration", "hash", hash)
+
+		err = m.saveLayout(hash, currentLayout)
+		if err != nil {
+			return err
+		}
+
+		m.hooks.Start(m.newHookEvent(config.HookLayoutUpdated, hash, latestHash, savedLayout, currentLayout))
+
+		return nil
+	default:
+		// Otherwise, we aren't updating layout, or creating a new one, we're simply switching to
+		// another layout. In other words, we should just apply the saved configuration.
+		m.logger.Infow("switching to existing configuration", "hash", hash, "previous_hash", latestHash)
+
+		return m.switchLayout(hash, "", savedLayout)
+	}
+}
+
+// applyLayout applies the given layout for the outputs with the given hash, after applying any
+// rules that are enforced over saved layouts to it. The pre-apply and post-apply hooks are run
+// around it, with the given profile name, if any. The outputs as they were before are returned.
+func (m *Manager) applyLayout(hash, profile string, layout []Output) ([]Output, error) {
+	layout = applyRules(m.logger, layout, m.rules, true)
+
+	oldLayout, err := m.display.GetOutputs()
+	if err != nil {
+		return nil, err
+	}
+
+	latestHash, err := m.store.LatestHash()
+	if err != nil {
+		return nil, err
+	}
+
+	hookEvent := m.newHookEvent(config.HookPreApply, hash, latestHash, oldLayout, layout)
+	hookEvent.Profile = profile
+
+	// Pre-apply hooks have to finish before the layout is applied, for them to be of any use.
+	m.hooks.Run(hookEvent)
+
+	err = m.display.ApplyLayout(layout)
+	if err != nil {
+		// Some outputs may have been configured, so there's no telling what state they're in.
+		m.expected = nil
+
+		result := event.NewResult(event.TypeLayoutFailed, hash, m.event)
+		result.Error = err.Error()
+		m.bus.Publish(result)
+
+		return nil, err
+	}
+
+	m.generation++
+	m.expected = &expectation{
+		generation: m.generation,
+		hash:       hash,
+		layout:     layout,
+	}
+
+	m.logger.Debugw("applied layout", "hash", hash, "generation", m.generation)
+
+	m.restoreWorkspaces(hash, layout)
+	m.runCommands(hash, profile)
+
+	hookEvent.Name = config.HookPostApply
+	m.hooks.Start(hookEvent)
+
+	m.bus.Publish(event.NewResult(event.TypeLayoutApplied, hash, m.event))
+
+	return oldLayout, nil
+}
+
+// restoreWorkspaces moves i3's workspaces back to the outputs they were on when the layout with the
+// given hash was saved, as long as those outputs are enabled in the given layout. Failing to do so
+// doesn't stop the layout from being applied, so it's only logged.
+func (m *Manager) restoreWorkspaces(hash string, layout []Output) {
+	if m.i3Client == nil {
+		return
+	}
+
+	saved, err := m.store.Workspaces(hash)
+	if err != nil {
+		m.logger.Warnw("failed to read saved workspaces", "hash", hash, "error", err)
+		return
+	}
+
+	enabled := make(map[string]bool)
+	for _, output := range layout {
+		enabled[output.Name] = output.IsConnected && output.IsEnabled
+	}
+
+	var workspaces []i3.Workspace
+	for _, workspace := range saved {
+		if enabled[workspace.Output] {
+			workspaces = append(workspaces, workspace)
+		}
+	}
+
+	err = m.i3Client.Restore(workspaces)
+	if err != nil {
+		m.logger.Warnw("failed to restore workspaces", "hash", hash, "error", err)
+	}
+}
+
+// runCommands runs the i3 commands set for the layout with the given hash, followed by those set
+// for the given profile, if any. Failing commands are logged, and don't stop the layout from being
+// applied.
+func (m *Manager) runCommands(hash, profile string) {
+	if m.i3Client == nil {
+		return
+	}
+
+	commands, err := m.store.Commands(hash)
+	if err != nil {
+		m.logger.Warnw("failed to read i3 commands", "hash", hash, "error", err)
+		return
+	}
+
+	if profile != "" {
+		p, err := m.store.Profile(profile)
+		if err != nil {
+			m.logger.Warnw("failed to read i3 commands", "profile", profile, "error", err)
+			return
+		}
+
+		commands = append(commands, p.Commands...)
+	}
+
+	if len(commands) == 0 {
+		return
+	}
+
+	failed := m.i3Client.RunCommands(commands)
+	m.logger.Infow("ran i3 commands", "hash", hash, "profile", profile, "commands", len(commands), "failed", failed)
+}
+
+// saveWorkspaces saves which outputs i3's workspaces are currently on, with the layout with the
+// given hash. Failing to do so doesn't stop the layout being saved, so it's only logged.
+func (m *Manager) saveWorkspaces(hash string) {
+	if m.i3Client == nil {
+		return
+	}
+
+	workspaces, err := m.i3Client.Workspaces()
+	if err != nil {
+		m.logger.Warnw("failed to read workspaces", "hash", hash, "error", err)
+		return
+	}
+
+	if len(workspaces) == 0 {
+		return
+	}
+
+	err = m.store.SaveWorkspaces(hash, workspaces)
+	if err != nil {
+		m.logger.Warnw("failed to save workspaces", "hash", hash, "error", err)
+	}
+}
+
+// switchLayout applies the given saved layout for the outputs with the given hash, and marks it as
+// the latest layout. The given profile name is passed to hooks, if it's being activated.
+func (m *Manager) switchLayout(hash, profile string, layout []Output) error {
+	latestHash, err := m.store.LatestHash()
+	if err != nil {
+		return err
+	}
+
+	oldLayout, err := m.applyLayout(hash, profile, layout)
+	if err != nil {
+		return err
+	}
+
+	err = m.store.SetLatestHash(hash)
+	if err != nil {
+		return err
+	}
+
+	hookEvent := m.newHookEvent(config.HookLayoutSwitched, hash, latestHash, oldLayout, layout)
+	hookEvent.Profile = profile
+
+	m.hooks.Start(hookEvent)
+
+	return nil
+}
+
+// findBaseLayout returns the saved layout that a new layout for the given outputs should be based
+// on, according to the configured strategy. If there isn't one, an empty hash is returned.
+func (m *Manager) findBaseLayout(current []Output) (string, []Output, error) {
+	if m.config.Strategy == config.StrategyRow {
+		return "", nil, nil
+	}
+
+	layouts, err := m.store.Layouts()
+	if err != nil {
+		return "", nil, err
+	}
+
+	hash, layout := findClosestLayout(current, layouts)
+
+	return hash, layout, nil
+}
+
+// Revisions returns the history of the layout that the given reference refers to, along with it's
+// full hash.
+func (m *Manager) Revisions(ref string) (string, []Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash, err := m.findHash(ref)
+	if err != nil {
+		return "", nil, err
+	}
+
+	revisions, err := m.store.Revisions(hash)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return hash, revisions, nil
+}
+
+// DiffRevisions returns the differences between two revisions of the layout that the given
+// reference refers to.
+func (m *Manager) DiffRevisions(ref string, fromID, toID int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash, err := m.findHash(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := m.store.Revision(hash, fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := m.store.Revision(hash, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffLayouts(from.Outputs, to.Outputs), nil
+}
+
+// Revert restores the given revision of the layout that the given reference refers to, saving it
+// as a new revision. If the layout is for the currently connected outputs, it is also applied. The
+// returned boolean reports whether or not the layout was applied.
+func (m *Manager) Revert(ref string, id int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	defer m.begin(event.New(event.SourceControl, event.ReasonUserRequest))()
+
+	hash, err := m.findHash(ref)
+	if err != nil {
+		return false, err
+	}
+
+	revision, err := m.store.Revision(hash, id)
+	if err != nil {
+		return false, err
+	}
+
+	err = m.checkConnected(hash)
+	if err == ErrNotConnected {
+		m.logger.Infow("reverting a configuration that isn't connected", "hash", hash, "revision", id)
+
+		// This isn't saved with saveLayout, because it's outputs aren't connected, so i3's workspaces
+		// aren't on them, and it mustn't become the latest layout.
+		err = m.store.SaveLayout(hash, revision.Outputs)
+		if err != nil {
+			return false, err
+		}
+
+		m.bus.Publish(event.NewResult(event.TypeLayoutSaved, hash, m.event))
+
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	m.logger.Infow("reverting configuration", "hash", hash, "revision", id)
+
+	savedLayout, err := m.store.Layout(hash)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = m.applyLayout(hash, "", revision.Outputs)
+	if err != nil {
+		return false, err
+	}
+
+	err = m.saveLayout(hash, revision.Outputs)
+	if err != nil {
+		return false, err
+	}
+
+	m.hooks.Start(m.newHookEvent(config.HookLayoutUpdated, hash, hash, savedLayout, revision.Outputs))
+
+	return true, nil
+}
+
+// SaveProfile saves the layout that the given reference refers to as a profile with the given
+// name. If a profile with that name already exists, it is replaced.
+func (m *Manager) SaveProfile(name, ref string) (Profile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash, err := m.store.FindHash(ref)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	return m.saveProfile(name, hash)
+}
+
+// Profiles returns every saved profile, along with the name of the active profile, if any.
+func (m *Manager) Profiles() ([]Profile, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	profiles, err := m.store.Profiles()
+	if err != nil {
+		return nil, "", err
+	}
+
+	active, err := m.activeProfile(profiles)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return profiles, active, nil
+}
+
+// ActivateProfile applies the profile with the given name, as long as it's outputs are the ones
+// that are currently connected. The profile's layout becomes the saved layout for those outputs.
+func (m *Manager) ActivateProfile(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	defer m.begin(event.New(event.SourceControl, event.ReasonUserRequest))()
+
+	return m.activateProfile(name)
+}
+
+// DeleteProfile deletes the profile with the given name. The layout it was made from is kept.
+func (m *Manager) DeleteProfile(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.store.DeleteProfile(name)
+}
+
+// Status returns the status of the currently connected outputs.
+func (m *Manager) Status() (Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var status Status
+
+	currentLayout, err := m.display.GetOutputs()
+	if err != nil {
+		return status, err
+	}
+
+	status.Outputs = currentLayout
+
+	status.Hash, err = calculateHashForOutputs(currentLayout)
+	if err != nil {
+		return status, err
+	}
+
+	savedLayout, err := m.store.Layout(status.Hash)
+	if err != nil {
+		return status, err
+	}
+
+	status.IsSaved = savedLayout != nil
+	status.IsPaused = m.paused
+
+	status.LatestHash, err = m.store.LatestHash()
+	if err != nil {
+		return status, err
+	}
+
+	profiles, err := m.store.Profiles()
+	if err != nil {
+		return status, err
+	}
+
+	status.ActiveProfile, err = m.activeProfile(profiles)
+
+	return status, err
+}
+
+// Layouts returns information about every saved layout.
+func (m *Manager) Layouts() ([]LayoutInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hashes, err := m.store.Hashes()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]LayoutInfo, 0, len(hashes))
+	for _, hash := range hashes {
+		info, err := m.layoutInfo(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// Layout returns information about the layout that the given reference refers to. The reference
+// may be the name of a profile, in which case the layout the profile is for is returned.
+func (m *Manager) Layout(ref string) (LayoutInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash, err := m.findHash(ref)
+	if err != nil {
+		return LayoutInfo{}, err
+	}
+
+	return m.layoutInfo(hash)
+}
+
+// Apply applies the layout that the given reference refers to, as long as it's for the currently
+// connected outputs. If the reference is the name of a profile, that profile is activated.
+func (m *Manager) Apply(ref string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	action := event.Action{Type: event.ActionApply, Ref: ref}
+	defer m.begin(event.New(event.SourceControl, event.ReasonUserRequest).WithAction(action))()
+
+	return m.apply(ref)
+}
+
+// apply does the work of Apply. The manager must be locked when this is called.
+func (m *Manager) apply(ref string) error {
+	if _, err := m.store.Profile(ref); err == nil {
+		return m.activateProfile(ref)
+	}
+
+	hash, err := m.store.FindHash(ref)
+	if err != nil {
+		return err
+	}
+
+	layout, err := m.store.Layout(hash)
+	if err != nil {
+		return err
+	}
+
+	err = m.checkConnected(hash)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Infow("applying configuration", "hash", hash)
+
+	return m.switchLayout(hash, "", layout)
+}
+
+// Commands returns the i3 commands set for the layout or profile that the given reference refers
+// to. If the reference is the name of a profile, the profile's commands are returned.
+func (m *Manager) Commands(ref string) (Commands, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if profile, err := m.store.Profile(ref); err == nil {
+		return Commands{Hash: profile.Hash, Profile: profile.Name, Commands: profile.Commands}, nil
+	}
+
+	hash, err := m.store.FindHash(ref)
+	if err != nil {
+		return Commands{}, err
+	}
+
+	commands, err := m.store.Commands(hash)
+	if err != nil {
+		return Commands{}, err
+	}
+
+	return Commands{Hash: hash, Commands: commands}, nil
+}
+
+// SetCommands sets the i3 commands that are run after the layout or profile that the given
+// reference refers to is applied, replacing any that were set before. If no commands are given,
+// the commands are removed.
+func (m *Manager) SetCommands(ref string, commands []string) (Commands, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if profile, err := m.store.Profile(ref); err == nil {
+		profile.Commands = commands
+
+		m.logger.Infow("setting i3 commands", "profile", profile.Name, "commands", len(commands))
+
+		return Commands{Hash: profile.Hash, Profile: profile.Name, Commands: commands}, m.store.SaveProfile(profile)
+	}
+
+	hash, err := m.store.FindHash(ref)
+	if err != nil {
+		return Commands{}, err
+	}
+
+	m.logger.Infow("setting i3 commands", "hash", hash, "commands", len(commands))
+
+	return Commands{Hash: hash, Commands: commands}, m.store.SaveCommands(hash, commands)
+}
+
+// DeleteLayout deletes the layout with the given hash (or unique prefix of one), along with it's
+// history. Profiles made from the layout are kept. The deleted layout's hash is returned.
+func (m *Manager) DeleteLayout(ref string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash, err := m.store.FindHash(ref)
+	if err != nil {
+		return "", err
+	}
+
+	m.logger.Infow("deleting configuration", "hash", hash)
+
+	return hash, m.store.DeleteLayout(hash)
+}
+
+// saveProfile saves the layout with the given hash as a profile with the given name.
+func (m *Manager) saveProfile(name, hash string) (Profile, error) {
+	layout, err := m.store.Layout(hash)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	profile := Profile{
+		Name:      name,
+		Hash:      hash,
+		Outputs:   layout,
+		CreatedAt: time.Now(),
+	}
+
+	// Replacing a profile's layout shouldn't lose the commands that were set for it.
+	if existing, err := m.store.Profile(name); err == nil {
+		profile.Commands = existing.Commands
+	}
+
+	err = m.store.SaveProfile(profile)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	m.logger.Infow("saved profile", "profile", name, "hash", hash)
+
+	return profile, nil
+}
+
+// activateProfile applies the profile with the given name, if it's outputs are connected.
+func (m *Manager) activateProfile(name string) error {
+	profile, err := m.store.Profile(name)
+	if err != nil {
+		return err
+	}
+
+	err = m.checkConnected(profile.Hash)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Infow("activating profile", "profile", name, "hash", profile.Hash)
+
+	err = m.switchLayout(profile.Hash, name, profile.Outputs)
+	if err != nil {
+		return err
+	}
+
+	err = m.saveLayout(profile.Hash, profile.Outputs)
+	if err != nil {
+		return err
+	}
+
+	profile.LastUsedAt = time.Now()
+
+	return m.store.SaveProfile(profile)
+}
+
+// activeProfile returns the name of the active profile, if any. The active profile is the most
+// recently used of the given profiles that matches the latest layout.
+func (m *Manager) activeProfile(profiles []Profile) (string, error) {
+	latestHash, err := m.store.LatestHash()
+	if err != nil || latestHash == "" {
+		return "", err
+	}
+
+	latestLayout, err := m.store.Layout(latestHash)
+	if err != nil {
+		return "", err
+	}
+
+	var active Profile
+	for _, profile := range profiles {
+		if profile.Hash != latestHash || len(DiffLayouts(profile.Outputs, latestLayout)) > 0 {
+			continue
+		}
+
+		if active.Name == "" || profile.LastUsedAt.After(active.LastUsedAt) {
+			active = profile
+		}
+	}
+
+	return active.Name, nil
+}
+
+// checkConnected returns ErrNotConnected if the given hash isn't the hash of the currently
+// connected outputs.
+func (m *Manager) checkConnected(hash string) error {
+	currentLayout, err := m.display.GetOutputs()
+	if err != nil {
+		return err
+	}
+
+	currentHash, err := calculateHashForOutputs(currentLayout)
+	if err != nil {
+		return err
+	}
+
+	if currentHash != hash {
+		return ErrNotConnected
+	}
+
+	return nil
+}
+
+// findHash returns the hash of the layout that the given reference refers to. The reference may
+// be the name of a profile, or a layout hash (or unique prefix of one).
+func (m *Manager) findHash(ref string) (string, error) {
+	if profile, err := m.store.Profile(ref); err == nil {
+		return profile.Hash, nil
+	}
+
+	return m.store.FindHash(ref)
+}
+
+// layoutInfo returns information about the saved layout with the given hash.
+func (m *Manager) layoutInfo(hash string) (LayoutInfo, error) {
+	info := LayoutInfo{
+		Hash: hash,
+	}
+
+	layout, err := m.store.Layout(hash)
+	if err != nil {
+		return info, err
+	}
+
+	if layout == nil {
+		return info, ErrLayoutNotFound
+	}
+
+	info.Outputs = layout
+
+	info.Workspaces, err = m.store.Workspaces(hash)
+	if err != nil {
+		return info, err
+	}
+
+	latestHash, err := m.store.LatestHash()
+	if err != nil {
+		return info, err
+	}
+
+	info.IsLatest = hash == latestHash
+
+	revisions, err := m.store.Revisions(hash)
+	if err != nil {
+		return info, err
+	}
+
+	info.Revisions = len(revisions)
+	if len(revisions) > 0 {
+		info.UpdatedAt = revisions[len(revisions)-1].Time
+	}
+
+	profiles, err := m.store.Profiles()
+	if err != nil {
+		return info, err
+	}
+
+	for _, profile := range profiles {
+		if profile.Hash == hash {
+			info.Profiles = append(info.Profiles, profile.Name)
+		}
+	}
+
+	return info, nil
+}
+
+// saveLayout saves the given layout under the given hash, along with the outputs that i3's
+// workspaces are on, and marks it as the latest layout.
+func (m *Manager) saveLayout(hash string, layout []Output) error {
+	err := m.store.SaveLayout(hash, layout)
+	if err != nil {
+		return err
+	}
+
+	m.saveWorkspaces(hash)
+
+	err = m.store.SetLatestHash(hash)
+	if err != nil {
+		return err
+	}
+
+	m.bus.Publish(event.NewResult(event.TypeLayoutSaved, hash, m.event))
+
+	return nil
+}
+
+// newHookEvent returns a hook event with the given name, for a change from the old layout to the new
+// layout for the outputs with the given hash, caused by the event currently being handled.
+func (m *Manager) newHookEvent(name, hash, previousHash string, oldLayout, newLayout []Output) hook.Event {
+	evt := hook.Event{
+		Name:         name,
+		Hash:         hash,
+		PreviousHash: previousHash,
+		Old:          oldLayout,
+		New:          newLayout,
+		EventID:      m.event.ID,
+		Source:       string(m.event.Source),
+		Reason:       string(m.event.Reason),
+	}
+
+	for _, output := range newLayout {
+		if !output.IsConnected || !output.IsEnabled {
+			continue
+		}
+
+		evt.Outputs = append(evt.Outputs, output.Name)
+
+		if output.IsPrimary {
+			evt.Primary = output.Name
+		}
+	}
+
+	return evt
+}
+
+// isReapply returns true if events for the given reason should apply the saved layout for the
+// connected outputs, rather than saving the outputs as they are. That's the case when they may have
+// been configured by something else while i3adc wasn't looking (including while it was reconnecting
+// to an event source), or when the user asks for it.
+func isReapply(reason event.Reason) bool {
+	switch reason {
+	case event.ReasonStartup, event.ReasonResume, event.ReasonReconnect, event.ReasonUserRequest:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,319 @@
+package xrandr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/seeruk/i3adc/config"
+	"github.com/seeruk/i3adc/event"
+	"github.com/seeruk/i3adc/i3"
+	"github.com/seeruk/i3adc/logging/noop"
+	"github.com/seeruk/i3adc/state/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_HandleEvent(t *testing.T) {
+	laptop := testOutput("eDP-1", "laptop", true)
+	monitor := testOutput("DP-1", "monitor", true)
+
+	hotplug := func() event.Event {
+		return event.New(event.SourceI3, event.ReasonHotplug)
+	}
+
+	t.Run("should create a layout for new outputs", func(t *testing.T) {
+		manager, display, _, results := newTestManager(t, laptop, monitor)
+
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+
+		hash := hashOf(t, display.outputs)
+
+		layout, err := manager.store.Layout(hash)
+		assert.NoError(t, err)
+		assert.Equal(t, display.outputs, layout)
+		assert.Equal(t, 1, display.applied)
+		assert.Equal(t, []event.Type{event.TypeLayoutApplied, event.TypeLayoutSaved}, resultTypes(results))
+	})
+
+	t.Run("should ignore events caused by applying a layout", func(t *testing.T) {
+		manager, display, _, results := newTestManager(t, laptop, monitor)
+
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+		resultTypes(results)
+
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+
+		assert.Equal(t, 1, display.applied)
+		assert.Empty(t, resultTypes(results))
+		assert.NotNil(t, manager.expected)
+	})
+
+	t.Run("should save changes made after a layout is applied", func(t *testing.T) {
+		manager, display, _, results := newTestManager(t, laptop, monitor)
+
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+		resultTypes(results)
+
+		display.outputs[1].OffsetX = 100
+
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+
+		layout, err := manager.store.Layout(hashOf(t, display.outputs))
+		assert.NoError(t, err)
+		assert.Equal(t, 100, layout[1].OffsetX)
+		assert.Equal(t, []event.Type{event.TypeLayoutSaved}, resultTypes(results))
+		assert.Nil(t, manager.expected)
+	})
+
+	t.Run("should stop expecting a layout that failed to apply", func(t *testing.T) {
+		manager, display, _, results := newTestManager(t, laptop, monitor)
+
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+		resultTypes(results)
+
+		display.applyErr = errors.New("oops")
+
+		err := manager.HandleEvent(event.New(event.SourceSignal, event.ReasonUserRequest))
+		assert.EqualError(t, err, "oops")
+		assert.Nil(t, manager.expected)
+		assert.Equal(t, []event.Type{event.TypeLayoutFailed}, resultTypes(results))
+
+		// Whatever state the outputs were left in is the user's to keep, even if it looks like the
+		// layout that was applied before.
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+		assert.Equal(t, []event.Type{event.TypeLayoutSaved}, resultTypes(results))
+	})
+
+	t.Run("should re-apply the saved layout after reconnecting to i3", func(t *testing.T) {
+		manager, display, _, results := newTestManager(t, laptop, monitor)
+
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+
+		saved := append([]Output(nil), display.outputs...)
+		resultTypes(results)
+
+		display.outputs[1].IsEnabled = false
+
+		assert.NoError(t, manager.HandleEvent(event.New(event.SourceI3, event.ReasonReconnect)))
+		assert.Equal(t, saved, display.outputs)
+		assert.Equal(t, []event.Type{event.TypeLayoutApplied}, resultTypes(results))
+	})
+
+	t.Run("should switch to the saved layout, and restore it's workspaces", func(t *testing.T) {
+		manager, display, wm, results := newTestManager(t, laptop, monitor)
+
+		wm.workspaces = []i3.Workspace{
+			{Name: "1", Output: "eDP-1"},
+			{Name: "2", Output: "DP-1"},
+			{Name: "3", Output: "HDMI-1"},
+		}
+
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+
+		docked := display.outputs
+
+		display.outputs = []Output{laptop, testOutput("DP-1", "", false)}
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+
+		display.outputs = []Output{laptop, monitor}
+		assert.NoError(t, manager.HandleEvent(hotplug()))
+
+		assert.Equal(t, docked, display.outputs)
+		assert.Equal(t, 3, display.applied)
+		assert.Equal(t, wm.workspaces[:2], wm.restored)
+		assert.Contains(t, resultTypes(results), event.TypeLayoutApplied)
+	})
+}
+
+func TestManager_ActivateProfile(t *testing.T) {
+	laptop := testOutput("eDP-1", "laptop", true)
+	monitor := testOutput("DP-1", "monitor", true)
+
+	manager, display, wm, results := newTestManager(t, laptop, monitor)
+
+	assert.NoError(t, manager.HandleEvent(event.New(event.SourceI3, event.ReasonHotplug)))
+
+	hash, err := manager.Save("desk")
+	assert.NoError(t, err)
+
+	desk := append([]Output(nil), display.outputs...)
+
+	display.outputs[1].OffsetX = 100
+	assert.NoError(t, manager.HandleEvent(event.New(event.SourceI3, event.ReasonHotplug)))
+
+	wm.workspaces = []i3.Workspace{{Name: "1", Output: "DP-1"}}
+	resultTypes(results)
+
+	t.Run("should apply the profile, and save it with the workspaces", func(t *testing.T) {
+		assert.NoError(t, manager.ActivateProfile("desk"))
+
+		layout, err := manager.store.Layout(hash)
+		assert.NoError(t, err)
+		assert.Equal(t, desk, layout)
+		assert.Equal(t, desk, display.outputs)
+
+		workspaces, err := manager.store.Workspaces(hash)
+		assert.NoError(t, err)
+		assert.Equal(t, wm.workspaces, workspaces)
+
+		assert.Equal(t, []event.Type{event.TypeLayoutApplied, event.TypeLayoutSaved}, resultTypes(results))
+	})
+
+	t.Run("should be the active profile", func(t *testing.T) {
+		status, err := manager.Status()
+		if assert.NoError(t, err) {
+			assert.Equal(t, "desk", status.ActiveProfile)
+		}
+	})
+
+	t.Run("should not apply a profile for other outputs", func(t *testing.T) {
+		display.outputs = []Output{laptop}
+
+		assert.Equal(t, ErrNotConnected, manager.ActivateProfile("desk"))
+	})
+}
+
+func TestManager_Revert(t *testing.T) {
+	laptop := testOutput("eDP-1", "laptop", true)
+	monitor := testOutput("DP-1", "monitor", true)
+
+	t.Run("should apply a revision of the connected layout", func(t *testing.T) {
+		manager, display, _, results := newTestManager(t, laptop, monitor)
+
+		assert.NoError(t, manager.HandleEvent(event.New(event.SourceI3, event.ReasonHotplug)))
+
+		original := append([]Output(nil), display.outputs...)
+
+		display.outputs[1].OffsetX = 100
+		assert.NoError(t, manager.HandleEvent(event.New(event.SourceI3, event.ReasonHotplug)))
+		resultTypes(results)
+
+		applied, err := manager.Revert(hashOf(t, original), 1)
+		assert.NoError(t, err)
+		assert.True(t, applied)
+		assert.Equal(t, original, display.outputs)
+		assert.Equal(t, []event.Type{event.TypeLayoutApplied, event.TypeLayoutSaved}, resultTypes(results))
+	})
+
+	t.Run("should save a revision of a layout that isn't connected, without applying it", func(t *testing.T) {
+		manager, display, _, results := newTestManager(t, laptop)
+
+		assert.NoError(t, manager.HandleEvent(event.New(event.SourceI3, event.ReasonHotplug)))
+
+		undocked := hashOf(t, display.outputs)
+
+		display.outputs[0].OffsetX = 100
+		assert.NoError(t, manager.HandleEvent(event.New(event.SourceI3, event.ReasonHotplug)))
+
+		display.outputs = []Output{laptop, monitor}
+		assert.NoError(t, manager.HandleEvent(event.New(event.SourceI3, event.ReasonHotplug)))
+
+		docked := hashOf(t, display.outputs)
+		applied := display.applied
+		resultTypes(results)
+
+		isApplied, err := manager.Revert(undocked, 1)
+		assert.NoError(t, err)
+		assert.False(t, isApplied)
+		assert.Equal(t, applied, display.applied)
+
+		layout, err := manager.store.Layout(undocked)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, layout[0].OffsetX)
+
+		latestHash, err := manager.store.LatestHash()
+		assert.NoError(t, err)
+		assert.Equal(t, docked, latestHash)
+
+		assert.Equal(t, []event.Type{event.TypeLayoutSaved}, resultTypes(results))
+	})
+}
+
+// fakeDisplay is a Display that keeps it's outputs in memory. Applying a layout configures the
+// outputs exactly as they are in the layout.
+type fakeDisplay struct {
+	outputs  []Output
+	applied  int
+	applyErr error
+}
+
+// GetOutputs returns a copy of the outputs.
+func (d *fakeDisplay) GetOutputs() ([]Output, error) {
+	return append([]Output(nil), d.outputs...), nil
+}
+
+// ApplyLayout replaces the outputs with the given layout, unless it's been told to fail.
+func (d *fakeDisplay) ApplyLayout(layout []Output) error {
+	if d.applyErr != nil {
+		return d.applyErr
+	}
+
+	d.applied++
+	d.outputs = append([]Output(nil), layout...)
+
+	return nil
+}
+
+// fakeWindowManager is a WindowManager that has the workspaces it's given, and records those it's
+// asked to restore.
+type fakeWindowManager struct {
+	workspaces []i3.Workspace
+	restored   []i3.Workspace
+}
+
+// Workspaces returns the workspaces the window manager was given.
+func (w *fakeWindowManager) Workspaces() ([]i3.Workspace, error) {
+	return w.workspaces, nil
+}
+
+// Restore records the given workspaces.
+func (w *fakeWindowManager) Restore(workspaces []i3.Workspace) error {
+	w.restored = workspaces
+	return nil
+}
+
+// RunCommands does nothing.
+func (w *fakeWindowManager) RunCommands(commands []string) int {
+	return 0
+}
+
+// newTestManager returns a manager for a fake display with the given outputs, a fake window
+// manager, and a subscription to the manager's results.
+func newTestManager(t *testing.T, outputs ...Output) (*Manager, *fakeDisplay, *fakeWindowManager, *event.Subscription) {
+	display := &fakeDisplay{outputs: outputs}
+	wm := &fakeWindowManager{}
+
+	bus := event.NewBus(noop.NewLogger())
+	results := bus.Subscribe("test", 32, event.DropNewest, event.TypeLayoutApplied, event.TypeLayoutSaved, event.TypeLayoutFailed)
+
+	cfg := config.Default()
+	cfg.Events.Stabilise.Timeout = 0
+
+	manager := NewManager(NewStore(memory.NewBackend()), display, wm, nil, bus, cfg, noop.NewLogger())
+
+	return manager, display, wm, results
+}
+
+// resultTypes returns the types of the results received by the given subscription since it was
+// last called.
+func resultTypes(sub *event.Subscription) []event.Type {
+	var types []event.Type
+	for {
+		select {
+		case evt := <-sub.Events():
+			types = append(types, evt.Type)
+		default:
+			return types
+		}
+	}
+}
+
+// hashOf returns the hash of the given outputs.
+func hashOf(t *testing.T, outputs []Output) string {
+	hash, err := calculateHashForOutputs(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
@@ -0,0 +1,199 @@
+package xrandr
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/seeruk/i3adc/event"
+	"github.com/seeruk/i3adc/logging"
+)
+
+// PollThread is a background thread that reads the outputs at an interval, and publishes an event
+// to the event bus when they've changed. It's a fallback for drivers and remote X setups that never
+// send output events. While nothing changes, it polls less and less often, up to a maximum
+// interval, and goes back to the minimum as soon as something does.
+type PollThread struct {
+	getOutputs  func() ([]Output, error)
+	bus         *event.Bus
+	logger      logging.Logger
+	interval    time.Duration
+	maxInterval time.Duration
+
+	mu      sync.Mutex
+	ctx     context.Context
+	cfn     context.CancelFunc
+	stopped bool
+}
+
+// NewPollThread returns a new polling thread instance, that reads outputs using the given client,
+// and publishes events to the given bus. It polls at the given interval, backing off up to the
+// given maximum interval while nothing changes.
+func NewPollThread(client *Client, bus *event.Bus, logger logging.Logger, interval, maxInterval time.Duration) *PollThread {
+	logger = logger.With("module", "xrandr/poll")
+
+	return &PollThread{
+		getOutputs:  client.GetOutputs,
+		bus:         bus,
+		logger:      logger,
+		interval:    interval,
+		maxInterval: maxInterval,
+	}
+}
+
+// Start begins polling the outputs. The first poll only records how they are, as the outputs are
+// already looked at when the daemon starts.
+func (t *PollThread) Start() error {
+	t.logger.Info("thread started")
+
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		t.logger.Info("thread stopped")
+		return nil
+	}
+
+	t.ctx, t.cfn = context.WithCancel(context.Background())
+	ctx := t.ctx
+	t.mu.Unlock()
+
+	var last fingerprint
+	var hasLast bool
+
+	wait := t.interval
+
+	for {
+		current, err := t.poll()
+		switch {
+		case err != nil:
+			// The X server may be restarting, so this is worth backing off from too.
+			t.logger.Warnw("failed to poll outputs", "error", err)
+			wait = nextInterval(wait, t.interval, t.maxInterval, false)
+		case !hasLast:
+			last, hasLast = current, true
+		default:
+			changed := current != last
+			if changed {
+				t.logger.Debugw("outputs changed", "hash", current.hash, "previous_hash", last.hash)
+				t.bus.Publish(event.New(event.SourcePoll, changeReason(last, current)))
+			}
+
+			last = current
+			wait = nextInterval(wait, t.interval, t.maxInterval, changed)
+		}
+
+		select {
+		case <-ctx.Done():
+			t.logger.Info("thread stopped")
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Stop attempts to stop this thread.
+func (t *PollThread) Stop() error {
+	t.logger.Infow("thread stopping")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stopped = true
+
+	if t.cfn != nil {
+		t.cfn()
+	}
+
+	return nil
+}
+
+// poll reads the outputs, and returns their fingerprint.
+func (t *PollThread) poll() (fingerprint, error) {
+	outputs, err := t.getOutputs()
+	if err != nil {
+		return fingerprint{}, err
+	}
+
+	return fingerprintOutputs(outputs)
+}
+
+// fingerprint identifies the state of the outputs, so that polls can tell if anything changed.
+type fingerprint struct {
+	// hash is the hash of the connected outputs, which changes when outputs are connected or
+	// disconnected.
+	hash string
+	// config is a hash of how the connected outputs are configured.
+	config string
+}
+
+// fingerprintOutputs returns the fingerprint of the given outputs.
+func fingerprintOutputs(outputs []Output) (fingerprint, error) {
+	hash, err := calculateHashForOutputs(outputs)
+	if err != nil {
+		return fingerprint{}, err
+	}
+
+	sum := md5.New()
+
+	for _, output := range outputs {
+		if !output.IsConnected {
+			continue
+		}
+
+		io.WriteString(sum, output.Name)
+
+		if !output.IsEnabled {
+			io.WriteString(sum, ":disabled;")
+			continue
+		}
+
+		fmt.Fprintf(sum, ":%t:%s:%dx%d:%g:%d,%d:%d:%d:%g;",
+			output.IsPrimary,
+			output.ModeName,
+			output.Width,
+			output.Height,
+			output.Rate,
+			output.OffsetX,
+			output.OffsetY,
+			output.Rotation,
+			output.Reflection,
+			output.Scale,
+		)
+	}
+
+	return fingerprint{hash: hash, config: hex.EncodeToString(sum.Sum(nil))}, nil
+}
+
+// changeReason returns the reason for an event about the outputs changing between the given
+// fingerprints. If the connected outputs are the same, only their configuration changed.
+func changeReason(last, current fingerprint) event.Reason {
+	if last.hash != current.hash {
+		return event.ReasonHotplug
+	}
+
+	return event.ReasonManualChange
+}
+
+// nextInterval returns how long to wait before the next poll, given how long was waited before the
+// last one. If something changed, it's back to the minimum, otherwise the wait doubles, up to the
+// maximum.
+func nextInterval(last, min, max time.Duration, changed bool) time.Duration {
+	if changed || last < min {
+		return min
+	}
+
+	next := last * 2
+	if next > max {
+		next = max
+	}
+
+	if next < min {
+		next = min
+	}
+
+	return next
+}
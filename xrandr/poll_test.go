@@ -0,0 +1,183 @@
+package xrandr
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seeruk/i3adc/daemon"
+	"github.com/seeruk/i3adc/event"
+	"github.com/seeruk/i3adc/logging/noop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollThread(t *testing.T) {
+	laptop := Output{Name: "eDP-1", IsConnected: true, IsEnabled: true, ModeName: "1920x1080"}
+	monitor := Output{Name: "DP-1", IsConnected: true, IsEnabled: true, ModeName: "2560x1440"}
+
+	// newTestThread returns a running thread that polls whatever outputs are set with the returned
+	// function, and a subscription to the events it publishes.
+	newTestThread := func() (func([]Output), *event.Subscription, context.CancelFunc) {
+		var mu sync.Mutex
+		outputs := []Output{laptop}
+
+		bus := event.NewBus(noop.NewLogger())
+		sub := bus.Subscribe("test", 10, event.DropOldest)
+
+		thread := &PollThread{
+			getOutputs: func() ([]Output, error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				return outputs, nil
+			},
+			bus:         bus,
+			logger:      noop.NewLogger(),
+			interval:    5 * time.Millisecond,
+			maxInterval: 20 * time.Millisecond,
+		}
+
+		ctx, cfn := context.WithCancel(context.Background())
+		daemon.NewBackgroundThread(ctx, thread)
+
+		return func(o []Output) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			outputs = o
+		}, sub, cfn
+	}
+
+	t.Run("should not publish anything while nothing changes", func(t *testing.T) {
+		_, sub, cfn := newTestThread()
+		defer cfn()
+
+		select {
+		case evt := <-sub.Events():
+			t.Errorf("unexpected event: %+v", evt)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("should publish a hotplug event when outputs are connected", func(t *testing.T) {
+		set, sub, cfn := newTestThread()
+		defer cfn()
+
+		time.Sleep(50 * time.Millisecond)
+		set([]Output{laptop, monitor})
+
+		assertReason(t, event.ReasonHotplug, sub)
+	})
+
+	t.Run("should publish a manual change event when outputs are configured", func(t *testing.T) {
+		set, sub, cfn := newTestThread()
+		defer cfn()
+
+		moved := laptop
+		moved.OffsetX = 2560
+
+		time.Sleep(50 * time.Millisecond)
+		set([]Output{moved})
+
+		assertReason(t, event.ReasonManualChange, sub)
+	})
+
+	t.Run("should not start if it's stopped before it starts", func(t *testing.T) {
+		thread := &PollThread{
+			getOutputs:  func() ([]Output, error) { return []Output{laptop}, nil },
+			bus:         event.NewBus(noop.NewLogger()),
+			logger:      noop.NewLogger(),
+			interval:    time.Hour,
+			maxInterval: time.Hour,
+		}
+
+		assert.NoError(t, thread.Stop())
+
+		done := make(chan error, 1)
+		go func() { done <- thread.Start() }()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Error("expected thread to stop")
+		}
+	})
+}
+
+func TestFingerprintOutputs(t *testing.T) {
+	laptop := Output{Name: "eDP-1", IsConnected: true, IsEnabled: true, ModeName: "1920x1080"}
+	unplugged := Output{Name: "DP-1", IsConnected: false, IsEnabled: true, ModeName: "2560x1440"}
+
+	original, err := fingerprintOutputs([]Output{laptop})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should ignore disconnected outputs", func(t *testing.T) {
+		fp, err := fingerprintOutputs([]Output{laptop, unplugged})
+		if assert.NoError(t, err) {
+			assert.Equal(t, original, fp)
+		}
+	})
+
+	t.Run("should change with the configuration, but not the hash", func(t *testing.T) {
+		rotated := laptop
+		rotated.Rotation = RotationLeft
+
+		fp, err := fingerprintOutputs([]Output{rotated})
+		if assert.NoError(t, err) {
+			assert.Equal(t, original.hash, fp.hash)
+			assert.NotEqual(t, original.config, fp.config)
+		}
+	})
+
+	t.Run("should ignore the configuration of disabled outputs", func(t *testing.T) {
+		disabled := laptop
+		disabled.IsEnabled = false
+
+		moved := disabled
+		moved.OffsetX = 100
+
+		first, err := fingerprintOutputs([]Output{disabled})
+		assert.NoError(t, err)
+
+		second, err := fingerprintOutputs([]Output{moved})
+		assert.NoError(t, err)
+
+		assert.Equal(t, first, second)
+		assert.NotEqual(t, original, first)
+	})
+}
+
+func TestNextInterval(t *testing.T) {
+	min := time.Second
+	max := 8 * time.Second
+
+	t.Run("should double the interval while nothing changes", func(t *testing.T) {
+		assert.Equal(t, 2*time.Second, nextInterval(min, min, max, false))
+		assert.Equal(t, 8*time.Second, nextInterval(4*time.Second, min, max, false))
+	})
+
+	t.Run("should not go beyond the maximum", func(t *testing.T) {
+		assert.Equal(t, max, nextInterval(max, min, max, false))
+		assert.Equal(t, max, nextInterval(6*time.Second, min, max, false))
+	})
+
+	t.Run("should go back to the minimum when something changes", func(t *testing.T) {
+		assert.Equal(t, min, nextInterval(max, min, max, true))
+	})
+}
+
+// assertReason asserts that the next event received by the given subscription has the given
+// reason.
+func assertReason(t *testing.T, expected event.Reason, sub *event.Subscription) {
+	select {
+	case evt := <-sub.Events():
+		assert.Equal(t, event.SourcePoll, evt.Source)
+		assert.Equal(t, expected, evt.Reason)
+	case <-time.After(time.Second):
+		t.Error("timed out waiting for event")
+	}
+}
@@ -0,0 +1,324 @@
+package xrandr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/seeruk/i3adc/config"
+	"github.com/seeruk/i3adc/logging"
+)
+
+// applyRules returns a copy of the given layout with the given rules applied to it's connected
+// outputs. Rules are applied in order, so later rules win when several match the same output. If
+// enforcedOnly is true, only the rules that should be enforced over saved layouts are applied.
+func applyRules(logger logging.Logger, layout []Output, rules []config.Rule, enforcedOnly bool) []Output {
+	result := make([]Output, len(layout))
+	copy(result, layout)
+
+	// Relative positions may refer to any rule, enforced or not, so every rule is matched first.
+	matched := make(map[string]int)
+	for _, rule := range rules {
+		for i, output := range result {
+			if _, ok := matched[rule.Name]; !ok && rule.Name != "" && output.IsConnected && matchesRule(rule.Match, output) {
+				matched[rule.Name] = i
+			}
+		}
+	}
+
+	positions := make(map[int]config.Position)
+	for _, rule := range rules {
+		if enforcedOnly && !rule.Enforce {
+			continue
+		}
+
+		for i, output := range result {
+			if !output.IsConnected || !matchesRule(rule.Match, output) {
+				continue
+			}
+
+			logger.Debugw("applying rule", "rule", rule.Name, "output", output.Name)
+
+			wasEnabled := output.IsEnabled
+			applyRule(logger, result, i, rule)
+
+			switch {
+			case rule.Position != nil:
+				positions[i] = *rule.Position
+			case !wasEnabled && result[i].IsEnabled:
+				// Outputs turned on by a rule without a position go to the right of everything.
+				_, _, maxX := bounds(result, enabledIndexes(result))
+				positions[i] = config.Position{X: maxX}
+			}
+		}
+	}
+
+	placeOutputs(logger, result, positions, matched)
+
+	// Turning outputs off, or moving them, may leave the layout no longer starting at the origin, so
+	// the enabled outputs are moved back to it, keeping their positions relative to each other.
+	enabled := enabledIndexes(result)
+
+	minX, minY, _ := bounds(result, enabled)
+	for _, i := range enabled {
+		result[i].OffsetX -= minX
+		result[i].OffsetY -= minY
+	}
+
+	return result
+}
+
+// applyRule applies everything but the position of the given rule to the output at the given index
+// in the given outputs.
+func applyRule(logger logging.Logger, outputs []Output, i int, rule config.Rule) {
+	output := &outputs[i]
+
+	if rule.Enabled != nil {
+		output.IsEnabled = *rule.Enabled
+	}
+
+	if !output.IsEnabled {
+		output.IsPrimary = false
+		return
+	}
+
+	if rule.Primary != nil {
+		if *rule.Primary {
+			for j := range outputs {
+				outputs[j].IsPrimary = false
+			}
+		}
+
+		output.IsPrimary = *rule.Primary
+	}
+
+	if rule.Mode != "" || rule.Rate > 0 || output.ModeName == "" {
+		mode, ok := selectMode(*output, rule.Mode, rule.Rate)
+		if ok {
+			output.ModeName = mode.Name
+			output.Rate = mode.Rate
+		} else {
+			logger.Warnw("no mode matches rule", "rule", rule.Name, "output", output.Name, "mode", rule.Mode, "rate", rule.Rate)
+		}
+	}
+
+	if rule.Rotation != "" {
+		output.Rotation = rotationFromString(rule.Rotation)
+	}
+
+	if rule.Scale > 0 {
+		output.Scale = rule.Scale
+	}
+
+	// The size of an output is it's size on the screen, so it changes with rotation and scale.
+	mode, ok := findMode(*output)
+	if !ok {
+		return
+	}
+
+	width, height := float64(mode.Width), float64(mode.Height)
+	if output.Rotation == RotationLeft || output.Rotation == RotationRight {
+		width, height = height, width
+	}
+
+	if output.Scale > 0 {
+		width, height = width*output.Scale, height*output.Scale
+	}
+
+	output.Width = uint(math.Round(width))
+	output.Height = uint(math.Round(height))
+}
+
+// placeOutputs moves the outputs at the indexes in the given positions to those positions. Relative
+// positions are placed next to the output matched by the named rule, using the given rule matches.
+func placeOutputs(logger logging.Logger, outputs []Output, positions map[int]config.Position, matched map[string]int) {
+	indexes := make([]int, 0, len(positions))
+	for i := range positions {
+		indexes = append(indexes, i)
+	}
+
+	sort.Ints(indexes)
+
+	// Outputs may be placed relative to outputs that are placed relative to others, and so on. Each
+	// pass places at least one more output correctly, unless the rules refer to each other in a
+	// loop, in which case this just gives up eventually.
+	for pass := 0; pass < len(indexes); pass++ {
+		for _, i := range indexes {
+			position := positions[i]
+
+			relation, name := position.Relative()
+			if relation == "" {
+				outputs[i].OffsetX = position.X
+				outputs[i].OffsetY = position.Y
+				continue
+			}
+
+			ref, ok := matched[name]
+			if !ok || ref == i {
+				if pass == 0 {
+					logger.Warnw("rule to position output relative to is not matched", "output", outputs[i].Name, "rule", name)
+				}
+
+				continue
+			}
+
+			output, other := &outputs[i], outputs[ref]
+
+			switch relation {
+			case "left_of":
+				output.OffsetX = other.OffsetX - int(output.Width)
+				output.OffsetY = other.OffsetY
+			case "right_of":
+				output.OffsetX = other.OffsetX + int(other.Width)
+				output.OffsetY = other.OffsetY
+			case "above":
+				output.OffsetX = other.OffsetX
+				output.OffsetY = other.OffsetY - int(output.Height)
+			case "below":
+				output.OffsetX = other.OffsetX
+				output.OffsetY = other.OffsetY + int(other.Height)
+			}
+		}
+	}
+}
+
+// matchesRule returns true if the given output matches every field that is set in the given match.
+func matchesRule(match config.Match, output Output) bool {
+	if match.IsEmpty() {
+		return false
+	}
+
+	if match.Output != "" && match.Output != output.Name {
+		return false
+	}
+
+	if match.Connector != "" && !strings.EqualFold(match.Connector, connectorType(output.Name)) {
+		return false
+	}
+
+	if match.Manufacturer == "" && match.Model == "" && match.Serial == "" {
+		return true
+	}
+
+	edid, err := ParseEDID(output.Properties["EDID"])
+	if err != nil {
+		return false
+	}
+
+	if match.Manufacturer != "" && !strings.EqualFold(match.Manufacturer, edid.Manufacturer) {
+		return false
+	}
+
+	if match.Model != "" && match.Model != edid.Model && match.Model != fmt.Sprint(edid.ProductCode) {
+		return false
+	}
+
+	if match.Serial != "" && match.Serial != edid.Serial && match.Serial != fmt.Sprint(edid.SerialNumber) {
+		return false
+	}
+
+	return true
+}
+
+// connectorType returns the type of connector an output uses, from it's name, e.g. "HDMI" for an
+// output named "HDMI-1".
+func connectorType(name string) string {
+	end := strings.IndexFunc(name, func(r rune) bool {
+		return r == '-' || unicode.IsDigit(r)
+	})
+
+	if end < 0 {
+		return name
+	}
+
+	return name[:end]
+}
+
+// selectMode finds the given output's mode that best fits the given mode size (like "2560x1440") and
+// rate. If no size is given, the output's current size is kept, or it's preferred mode is used. If
+// no rate is given, the preferred rate for that size is used.
+func selectMode(output Output, size string, rate float64) (Mode, bool) {
+	var width, height uint
+
+	current, hasCurrent := findMode(output)
+
+	switch {
+	case size != "":
+		fmt.Sscanf(size, "%dx%d", &width, &height)
+	case hasCurrent:
+		width, height = current.Width, current.Height
+	default:
+		for _, mode := range output.Modes {
+			if mode.IsPreferred {
+				width, height = mode.Width, mode.Height
+				break
+			}
+		}
+	}
+
+	var best Mode
+	var found bool
+
+	for _, mode := range output.Modes {
+		if mode.Width != width || mode.Height != height {
+			continue
+		}
+
+		switch {
+		case !found:
+		case rate > 0 && math.Abs(mode.Rate-rate) < math.Abs(best.Rate-rate):
+		case rate <= 0 && mode.IsPreferred && !best.IsPreferred:
+		default:
+			continue
+		}
+
+		best, found = mode, true
+	}
+
+	return best, found
+}
+
+// findMode returns the mode that the given output is currently set to, if it's known.
+func findMode(output Output) (Mode, bool) {
+	var found Mode
+	var ok bool
+
+	for _, mode := range output.Modes {
+		if mode.Name != output.ModeName {
+			continue
+		}
+
+		// Several modes may share a name, so the one with the right rate wins, or else the first.
+		if !ok || (mode.Rate == output.Rate && found.Rate != output.Rate) {
+			found, ok = mode, true
+		}
+	}
+
+	return found, ok
+}
+
+// enabledIndexes returns the indexes of the enabled outputs in the given outputs.
+func enabledIndexes(outputs []Output) []int {
+	var indexes []int
+	for i, output := range outputs {
+		if output.IsEnabled {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes
+}
+
+// rotationFromString returns the rotation with the given name. Names are validated with the rest of
+// the configuration, so anything unknown is treated as normal.
+func rotationFromString(name string) Rotation {
+	for _, rotation := range []Rotation{RotationNormal, RotationLeft, RotationInverted, RotationRight} {
+		if rotation.String() == name {
+			return rotation
+		}
+	}
+
+	return RotationNormal
+}
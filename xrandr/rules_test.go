@@ -0,0 +1,115 @@
+package xrandr
+
+import (
+	"testing"
+
+	"github.com/seeruk/i3adc/config"
+	"github.com/seeruk/i3adc/logging/noop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyRules(t *testing.T) {
+	yes, no := true, false
+
+	laptop := testOutput("eDP-1", "laptop", true)
+	laptop.IsEnabled = true
+	laptop.IsPrimary = true
+	laptop.ModeName = "1920x1080"
+	laptop.Width, laptop.Height = 1920, 1080
+
+	dell := testOutput("DP-1", "", true)
+	dell.Properties["EDID"] = testEDID("DEL", 0xA0C4, 0, "DELL U2715H", "ABC123")
+	dell.Modes = []Mode{
+		{ID: 1, Name: "2560x1440", Width: 2560, Height: 1440, Rate: 59.95, IsPreferred: true},
+		{ID: 2, Name: "2560x1440", Width: 2560, Height: 1440, Rate: 30},
+		{ID: 3, Name: "1920x1080", Width: 1920, Height: 1080, Rate: 60},
+	}
+	dell.IsEnabled = true
+	dell.ModeName = "1920x1080"
+	dell.Rate = 60
+	dell.Width, dell.Height = 1920, 1080
+	dell.OffsetX = 1920
+
+	rules := []config.Rule{
+		{Name: "laptop", Match: config.Match{Connector: "eDP"}},
+		{
+			Name:     "dell",
+			Match:    config.Match{Manufacturer: "DEL", Serial: "ABC123"},
+			Primary:  &yes,
+			Mode:     "2560x1440",
+			Rotation: "left",
+			Position: &config.Position{RightOf: "laptop"},
+		},
+	}
+
+	t.Run("should configure outputs matching rules", func(t *testing.T) {
+		result := applyRules(noop.NewLogger(), []Output{laptop, dell}, rules, false)
+
+		assert.False(t, result[0].IsPrimary)
+		assert.True(t, result[1].IsPrimary)
+		assert.Equal(t, "2560x1440", result[1].ModeName)
+		assert.Equal(t, 59.95, result[1].Rate)
+		assert.Equal(t, RotationLeft, result[1].Rotation)
+		assert.Equal(t, uint(1440), result[1].Width)
+		assert.Equal(t, uint(2560), result[1].Height)
+		assert.Equal(t, 1920, result[1].OffsetX)
+	})
+
+	t.Run("should choose the mode closest to the rate", func(t *testing.T) {
+		rules := []config.Rule{{Match: config.Match{Output: "DP-1"}, Mode: "2560x1440", Rate: 30}}
+
+		result := applyRules(noop.NewLogger(), []Output{laptop, dell}, rules, false)
+
+		assert.Equal(t, 30.0, result[1].Rate)
+	})
+
+	t.Run("should only apply enforced rules when asked to", func(t *testing.T) {
+		rules := []config.Rule{
+			{Name: "laptop", Match: config.Match{Connector: "edp"}, Enabled: &no, Enforce: true},
+			{Match: config.Match{Model: "DELL U2715H"}, Rotation: "inverted"},
+		}
+
+		result := applyRules(noop.NewLogger(), []Output{laptop, dell}, rules, true)
+
+		assert.False(t, result[0].IsEnabled)
+		assert.False(t, result[0].IsPrimary)
+		assert.Equal(t, RotationNormal, result[1].Rotation)
+
+		// With the laptop turned off, the layout should be moved back to the origin.
+		assert.Equal(t, 0, result[1].OffsetX)
+	})
+
+	t.Run("should place outputs relative to each other", func(t *testing.T) {
+		rules := []config.Rule{
+			{Name: "laptop", Match: config.Match{Connector: "eDP"}, Position: &config.Position{Below: "dell"}},
+			{Name: "dell", Match: config.Match{Output: "DP-1"}, Position: &config.Position{X: 0, Y: 0}},
+		}
+
+		result := applyRules(noop.NewLogger(), []Output{laptop, dell}, rules, false)
+
+		assert.Equal(t, 0, result[0].OffsetX)
+		assert.Equal(t, 1080, result[0].OffsetY)
+		assert.Equal(t, 0, result[1].OffsetX)
+		assert.Equal(t, 0, result[1].OffsetY)
+	})
+
+	t.Run("should not change the given layout", func(t *testing.T) {
+		layout := []Output{laptop, dell}
+
+		applyRules(noop.NewLogger(), layout, rules, false)
+
+		assert.Equal(t, []Output{laptop, dell}, layout)
+	})
+}
+
+func TestConnectorType(t *testing.T) {
+	for name, expected := range map[string]string{
+		"eDP-1":         "eDP",
+		"eDP1":          "eDP",
+		"HDMI-A-0":      "HDMI",
+		"DisplayPort-2": "DisplayPort",
+		"VIRTUAL":       "VIRTUAL",
+	} {
+		assert.Equal(t, expected, connectorType(name), name)
+	}
+}
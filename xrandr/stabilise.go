@@ -0,0 +1,142 @@
+package xrandr
+
+import (
+	"time"
+)
+
+// incompleteReads is how many reads in a row have to agree before outputs that are still missing
+// an EDID or modes are used as they are. Some outputs (like projectors, KVMs, and VGA adapters)
+// never have an EDID, and they shouldn't hold up every event until the timeout.
+const incompleteReads = 5
+
+// stabilised is the result of waiting for the outputs to stabilise.
+type stabilised struct {
+	// Outputs are the outputs as they were last read.
+	Outputs []Output
+	// Reads is how many times the outputs were read.
+	Reads int
+	// WaitedFor describes everything that was waited for, in the order it was first noticed.
+	WaitedFor []string
+	// Incomplete describes anything that was still missing from the outputs when they stopped
+	// changing, and were used anyway.
+	Incomplete []string
+	// TimedOut is true if the outputs still hadn't stabilised when the timeout expired.
+	TimedOut bool
+}
+
+// stabiliseOutputs reads the outputs with the given function, at the given interval, until two
+// reads in a row agree, and every connected output has an EDID and modes, or until the given
+// timeout expires. On hotplug, outputs are often read mid-handshake, and that transient state
+// shouldn't be mistaken for a new layout. Outputs that are still missing an EDID or modes once
+// enough reads in a row agree are used as they are. If the timeout is 0, the outputs are read once.
+func stabiliseOutputs(read func() ([]Output, error), interval, timeout time.Duration) (stabilised, error) {
+	var result stabilised
+	var last fingerprint
+	var lastOutputs []Output
+	var agreeing int
+
+	deadline := time.Now().Add(timeout)
+	noted := make(map[string]bool)
+
+	note := func(what string) {
+		if !noted[what] {
+			noted[what] = true
+			result.WaitedFor = append(result.WaitedFor, what)
+		}
+	}
+
+	for {
+		outputs, err := read()
+		if err != nil {
+			return result, err
+		}
+
+		result.Outputs = outputs
+		result.Reads++
+
+		if timeout <= 0 {
+			return result, nil
+		}
+
+		current, err := fingerprintOutputs(outputs)
+		if err != nil {
+			return result, err
+		}
+
+		missing := missingOutputInfo(outputs)
+		for _, what := range missing {
+			note(what)
+		}
+
+		switch {
+		case result.Reads == 1:
+			agreeing = 1
+		case current != last, !sameModes(outputs, lastOutputs):
+			note("outputs to stop changing")
+			agreeing = 1
+		default:
+			agreeing++
+		}
+
+		switch {
+		case agreeing >= 2 && len(missing) == 0:
+			return result, nil
+		case agreeing >= incompleteReads:
+			result.Incomplete = missing
+			return result, nil
+		}
+
+		last = current
+		lastOutputs = outputs
+
+		if time.Now().Add(interval).After(deadline) {
+			result.TimedOut = true
+			return result, nil
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// sameModes returns true if each output has the same modes in both of the given reads.
+func sameModes(a, b []Output) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Name != b[i].Name || len(a[i].Modes) != len(b[i].Modes) {
+			return false
+		}
+
+		for j := range a[i].Modes {
+			if a[i].Modes[j] != b[i].Modes[j] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// missingOutputInfo returns a description of each thing that's missing from the given outputs,
+// which would be there once they've finished connecting.
+func missingOutputInfo(outputs []Output) []string {
+	var missing []string
+
+	for _, output := range outputs {
+		if !output.IsConnected {
+			continue
+		}
+
+		if len(output.Properties["EDID"]) == 0 {
+			missing = append(missing, "EDID of "+output.Name)
+		}
+
+		if len(output.Modes) == 0 {
+			missing = append(missing, "modes of "+output.Name)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,128 @@
+package xrandr
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStabiliseOutputs(t *testing.T) {
+	edid := testEDID("DEL", 0xa0ec, 12345, "DELL U2515H", "9X2VY5A")
+	modes := []Mode{{ID: 1, Name: "2560x1440", Width: 2560, Height: 1440, IsPreferred: true}}
+
+	laptop := testOutput("eDP-1", "laptop", true)
+	laptop.Modes = modes
+
+	ready := Output{Name: "DP-1", IsConnected: true, Properties: Properties{"EDID": edid}, Modes: modes}
+
+	noEDID := ready
+	noEDID.Properties = Properties{}
+
+	noModes := ready
+	noModes.Modes = nil
+
+	// reads returns a function that returns each of the given snapshots in turn, repeating the
+	// last one forever.
+	reads := func(snapshots ...[]Output) func() ([]Output, error) {
+		i := 0
+		return func() ([]Output, error) {
+			snapshot := snapshots[i]
+			if i < len(snapshots)-1 {
+				i++
+			}
+
+			return snapshot, nil
+		}
+	}
+
+	interval := time.Millisecond
+
+	t.Run("should stop once two reads agree", func(t *testing.T) {
+		result, err := stabiliseOutputs(reads([]Output{laptop, ready}), interval, time.Second)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, 2, result.Reads)
+		assert.Empty(t, result.WaitedFor)
+		assert.False(t, result.TimedOut)
+	})
+
+	t.Run("should wait for EDIDs and modes", func(t *testing.T) {
+		read := reads(
+			[]Output{laptop, noEDID},
+			[]Output{laptop, noEDID},
+			[]Output{laptop, noModes},
+			[]Output{laptop, ready},
+		)
+
+		result, err := stabiliseOutputs(read, interval, time.Second)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []Output{laptop, ready}, result.Outputs)
+		assert.Equal(t, 5, result.Reads)
+		assert.Equal(t, []string{"EDID of DP-1", "modes of DP-1", "outputs to stop changing"}, result.WaitedFor)
+		assert.False(t, result.TimedOut)
+	})
+
+	t.Run("should use outputs without an EDID once they stop changing", func(t *testing.T) {
+		result, err := stabiliseOutputs(reads([]Output{laptop, noEDID}), interval, time.Second)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []Output{laptop, noEDID}, result.Outputs)
+		assert.Equal(t, incompleteReads, result.Reads)
+		assert.Equal(t, []string{"EDID of DP-1"}, result.Incomplete)
+		assert.False(t, result.TimedOut)
+	})
+
+	t.Run("should give up once the timeout expires", func(t *testing.T) {
+		var i int
+		flapping := func() ([]Output, error) {
+			i++
+			if i%2 == 0 {
+				return []Output{laptop}, nil
+			}
+
+			return []Output{laptop, ready}, nil
+		}
+
+		result, err := stabiliseOutputs(flapping, interval, 20*time.Millisecond)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []string{"outputs to stop changing"}, result.WaitedFor)
+		assert.True(t, result.TimedOut)
+	})
+
+	t.Run("should ignore disconnected outputs", func(t *testing.T) {
+		unplugged := testOutput("HDMI-1", "", false)
+
+		result, err := stabiliseOutputs(reads([]Output{laptop, unplugged}), interval, time.Second)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 2, result.Reads)
+		}
+	})
+
+	t.Run("should read once if there's no timeout", func(t *testing.T) {
+		result, err := stabiliseOutputs(reads([]Output{noEDID}), interval, 0)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, result.Reads)
+		}
+	})
+
+	t.Run("should return errors from reading outputs", func(t *testing.T) {
+		read := func() ([]Output, error) {
+			return nil, errors.New("oops")
+		}
+
+		_, err := stabiliseOutputs(read, interval, time.Second)
+		assert.Error(t, err)
+	})
+}
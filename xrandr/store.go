@@ -0,0 +1,465 @@
+package xrandr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/seeruk/i3adc/i3"
+	"github.com/seeruk/i3adc/state"
+)
+
+// MaxRevisions is the maximum number of revisions that will be kept in each layout's history. Once
+// this limit is reached, the oldest revisions are discarded.
+const MaxRevisions = 20
+
+// Key prefixes for the other data stored alongside layouts.
+const (
+	keyPrefixCommands   = "commands/"
+	keyPrefixHistory    = "history/"
+	keyPrefixProfile    = "profile/"
+	keyPrefixWorkspaces = "workspaces/"
+)
+
+var (
+	// ErrAmbiguousHash is returned when a partial layout hash matches more than one layout.
+	ErrAmbiguousHash = errors.New("xrandr: ambiguous layout hash")
+	// ErrInvalidProfileName is returned when a profile name contains unsupported characters.
+	ErrInvalidProfileName = errors.New("xrandr: invalid profile name")
+	// ErrLayoutNotFound is returned when a layout can't be found.
+	ErrLayoutNotFound = errors.New("xrandr: layout not found")
+	// ErrProfileNotFound is returned when a profile can't be found.
+	ErrProfileNotFound = errors.New("xrandr: profile not found")
+)
+
+// RevisionNotFoundError is returned when a layout revision can't be found.
+type RevisionNotFoundError struct {
+	// ID is the ID of the revision that couldn't be found.
+	ID int
+}
+
+// Error implements the error interface for RevisionNotFoundError.
+func (e *RevisionNotFoundError) Error() string {
+	return fmt.Sprintf("xrandr: revision %d not found", e.ID)
+}
+
+// Revision is a single entry in a layout's history.
+type Revision struct {
+	ID      int       `json:"id"`
+	Time    time.Time `json:"time"`
+	Outputs []Output  `json:"outputs"`
+}
+
+// Profile is a layout that has been given a name by the user, so that it can be found and
+// activated again later. Several profiles may exist for the same set of outputs.
+type Profile struct {
+	Name       string    `json:"name"`
+	Hash       string    `json:"hash"`
+	Outputs    []Output  `json:"outputs"`
+	Commands   []string  `json:"commands,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// Store provides access to saved output layouts, on top of a generic state backend. Layouts are
+// stored as JSON, keyed by the hash of the outputs that were connected when they were saved.
+type Store struct {
+	backend state.Backend
+}
+
+// NewStore returns a new layout store instance.
+func NewStore(backend state.Backend) *Store {
+	return &Store{
+		backend: backend,
+	}
+}
+
+// Layout returns the saved layout for the given hash. If no layout is saved for that hash, then a
+// nil slice and nil error will be returned.
+func (s *Store) Layout(hash string) ([]Output, error) {
+	layoutBS, err := s.backend.Read(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if layoutBS == nil {
+		return nil, nil
+	}
+
+	var layout []Output
+
+	err = json.Unmarshal(layoutBS, &layout)
+	if err != nil {
+		return nil, fmt.Errorf("xrandr: failed to decode layout %q: %v", hash, err)
+	}
+
+	return layout, nil
+}
+
+// Layouts returns every saved layout, keyed by hash.
+func (s *Store) Layouts() (map[string][]Output, error) {
+	hashes, err := s.Hashes()
+	if err != nil {
+		return nil, err
+	}
+
+	layouts := make(map[string][]Output, len(hashes))
+	for _, hash := range hashes {
+		layout, err := s.Layout(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		layouts[hash] = layout
+	}
+
+	return layouts, nil
+}
+
+// Hashes returns the hashes of every saved layout, in sorted order.
+func (s *Store) Hashes() ([]string, error) {
+	keys, err := s.backend.Keys("")
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for _, key := range keys {
+		// Layouts share their bucket with other keys (e.g. the latest layout hash), but layout
+		// keys are always MD5 hashes, so anything else can be skipped.
+		if isLayoutHash(key) {
+			hashes = append(hashes, key)
+		}
+	}
+
+	return hashes, nil
+}
+
+// FindHash returns the full hash of the saved layout that the given reference refers to. The
+// reference may be a full hash, or a unique prefix of one. An empty reference refers to the latest
+// layout.
+func (s *Store) FindHash(ref string) (string, error) {
+	if ref == "" {
+		latestHash, err := s.LatestHash()
+		if err == nil && latestHash == "" {
+			err = ErrLayoutNotFound
+		}
+
+		return latestHash, err
+	}
+
+	hashes, err := s.Hashes()
+	if err != nil {
+		return "", err
+	}
+
+	var found string
+	for _, hash := range hashes {
+		if !strings.HasPrefix(hash, ref) {
+			continue
+		}
+
+		if found != "" {
+			return "", ErrAmbiguousHash
+		}
+
+		found = hash
+	}
+
+	if found == "" {
+		return "", ErrLayoutNotFound
+	}
+
+	return found, nil
+}
+
+// SaveLayout saves the given layout under the given hash, and adds it to the layout's history.
+func (s *Store) SaveLayout(hash string, layout []Output) error {
+	layoutBS, err := json.Marshal(layout)
+	if err != nil {
+		return err
+	}
+
+	err = s.backend.Write(hash, layoutBS)
+	if err != nil {
+		return err
+	}
+
+	return s.addRevision(hash, layout, layoutBS)
+}
+
+// DeleteLayout deletes the layout with the given hash, along with it's history, workspaces, and
+// commands. If it was the latest layout, then there will no longer be a latest layout.
+func (s *Store) DeleteLayout(hash string) error {
+	for _, key := range []string{hash, keyPrefixHistory + hash, keyPrefixWorkspaces + hash, keyPrefixCommands + hash} {
+		err := s.backend.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	latestHash, err := s.LatestHash()
+	if err != nil || latestHash != hash {
+		return err
+	}
+
+	return s.backend.Delete(state.KeyLatestLayout)
+}
+
+// Revisions returns the history of the layout with the given hash, oldest first.
+func (s *Store) Revisions(hash string) ([]Revision, error) {
+	historyBS, err := s.backend.Read(keyPrefixHistory + hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if historyBS == nil {
+		return nil, nil
+	}
+
+	var revisions []Revision
+
+	err = json.Unmarshal(historyBS, &revisions)
+	if err != nil {
+		return nil, fmt.Errorf("xrandr: failed to decode history of layout %q: %v", hash, err)
+	}
+
+	return revisions, nil
+}
+
+// Revision returns the revision with the given ID from the history of the layout with the given
+// hash. If there's no such revision, a *RevisionNotFoundError is returned.
+func (s *Store) Revision(hash string, id int) (Revision, error) {
+	revisions, err := s.Revisions(hash)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	for _, revision := range revisions {
+		if revision.ID == id {
+			return revision, nil
+		}
+	}
+
+	return Revision{}, &RevisionNotFoundError{ID: id}
+}
+
+// addRevision appends the given layout to the history of the layout with the given hash, unless
+// it's the same as the most recent revision. Only the most recent MaxRevisions are kept.
+func (s *Store) addRevision(hash string, layout []Output, layoutBS []byte) error {
+	revisions, err := s.Revisions(hash)
+	if err != nil {
+		return err
+	}
+
+	nextID := 1
+
+	if len(revisions) > 0 {
+		last := revisions[len(revisions)-1]
+
+		lastBS, err := json.Marshal(last.Outputs)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(lastBS, layoutBS) {
+			return nil
+		}
+
+		nextID = last.ID + 1
+	}
+
+	revisions = append(revisions, Revision{
+		ID:      nextID,
+		Time:    time.Now(),
+		Outputs: layout,
+	})
+
+	if len(revisions) > MaxRevisions {
+		revisions = revisions[len(revisions)-MaxRevisions:]
+	}
+
+	historyBS, err := json.Marshal(revisions)
+	if err != nil {
+		return err
+	}
+
+	return s.backend.Write(keyPrefixHistory+hash, historyBS)
+}
+
+// Workspaces returns the i3 workspaces saved with the layout with the given hash, if any.
+func (s *Store) Workspaces(hash string) ([]i3.Workspace, error) {
+	workspacesBS, err := s.backend.Read(keyPrefixWorkspaces + hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if workspacesBS == nil {
+		return nil, nil
+	}
+
+	var workspaces []i3.Workspace
+
+	err = json.Unmarshal(workspacesBS, &workspaces)
+	if err != nil {
+		return nil, fmt.Errorf("xrandr: failed to decode workspaces of layout %q: %v", hash, err)
+	}
+
+	return workspaces, nil
+}
+
+// SaveWorkspaces saves the given i3 workspaces with the layout with the given hash.
+func (s *Store) SaveWorkspaces(hash string, workspaces []i3.Workspace) error {
+	workspacesBS, err := json.Marshal(workspaces)
+	if err != nil {
+		return err
+	}
+
+	return s.backend.Write(keyPrefixWorkspaces+hash, workspacesBS)
+}
+
+// Commands returns the i3 commands set for the layout with the given hash, if any.
+func (s *Store) Commands(hash string) ([]string, error) {
+	commandsBS, err := s.backend.Read(keyPrefixCommands + hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if commandsBS == nil {
+		return nil, nil
+	}
+
+	var commands []string
+
+	err = json.Unmarshal(commandsBS, &commands)
+	if err != nil {
+		return nil, fmt.Errorf("xrandr: failed to decode commands of layout %q: %v", hash, err)
+	}
+
+	return commands, nil
+}
+
+// SaveCommands sets the i3 commands for the layout with the given hash. If there are no commands,
+// any that were set are removed.
+func (s *Store) SaveCommands(hash string, commands []string) error {
+	if len(commands) == 0 {
+		return s.backend.Delete(keyPrefixCommands + hash)
+	}
+
+	commandsBS, err := json.Marshal(commands)
+	if err != nil {
+		return err
+	}
+
+	return s.backend.Write(keyPrefixCommands+hash, commandsBS)
+}
+
+// Profile returns the profile with the given name.
+func (s *Store) Profile(name string) (Profile, error) {
+	var profile Profile
+
+	profileBS, err := s.backend.Read(keyPrefixProfile + name)
+	if err != nil {
+		return profile, err
+	}
+
+	if profileBS == nil {
+		return profile, ErrProfileNotFound
+	}
+
+	err = json.Unmarshal(profileBS, &profile)
+	if err != nil {
+		return profile, fmt.Errorf("xrandr: failed to decode profile %q: %v", name, err)
+	}
+
+	return profile, nil
+}
+
+// Profiles returns every saved profile, sorted by name.
+func (s *Store) Profiles() ([]Profile, error) {
+	keys, err := s.backend.Keys(keyPrefixProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]Profile, 0, len(keys))
+	for _, key := range keys {
+		profile, err := s.Profile(strings.TrimPrefix(key, keyPrefixProfile))
+		if err != nil {
+			return nil, err
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, nil
+}
+
+// SaveProfile saves the given profile, replacing any existing profile with the same name.
+func (s *Store) SaveProfile(profile Profile) error {
+	if !isValidProfileName(profile.Name) {
+		return ErrInvalidProfileName
+	}
+
+	profileBS, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+
+	return s.backend.Write(keyPrefixProfile+profile.Name, profileBS)
+}
+
+// DeleteProfile deletes the profile with the given name.
+func (s *Store) DeleteProfile(name string) error {
+	_, err := s.Profile(name)
+	if err != nil {
+		return err
+	}
+
+	return s.backend.Delete(keyPrefixProfile + name)
+}
+
+// LatestHash returns the hash of the most recently active layout. If there isn't one, an empty
+// string will be returned.
+func (s *Store) LatestHash() (string, error) {
+	latestHashBS, err := s.backend.Read(state.KeyLatestLayout)
+	if err != nil {
+		return "", err
+	}
+
+	return string(latestHashBS), nil
+}
+
+// SetLatestHash marks the layout with the given hash as the most recently active layout.
+func (s *Store) SetLatestHash(hash string) error {
+	return s.backend.Write(state.KeyLatestLayout, []byte(hash))
+}
+
+// isValidProfileName returns true if the given profile name is non-empty, and only contains
+// letters, digits, dashes, underscores, and dots.
+func isValidProfileName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// isLayoutHash returns true if the given key looks like a layout hash.
+func isLayoutHash(key string) bool {
+	bs, err := hex.DecodeString(key)
+	return err == nil && len(bs) == 16
+}
@@ -0,0 +1,142 @@
+package xrandr
+
+import (
+	"testing"
+
+	"github.com/seeruk/i3adc/i3"
+	"github.com/seeruk/i3adc/state/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_SaveLayout(t *testing.T) {
+	t.Run("should add each new layout to the history", func(t *testing.T) {
+		store := NewStore(memory.NewBackend())
+		hash := "0123456789abcdef0123456789abcdef"
+
+		first := []Output{testOutput("eDP-1", "laptop", true)}
+		second := []Output{testOutput("eDP-1", "laptop", true), testOutput("DP-1", "dell", true)}
+
+		assert.NoError(t, store.SaveLayout(hash, first))
+		assert.NoError(t, store.SaveLayout(hash, first))
+		assert.NoError(t, store.SaveLayout(hash, second))
+
+		revisions, err := store.Revisions(hash)
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 2)
+		assert.Equal(t, 1, revisions[0].ID)
+		assert.Equal(t, 2, revisions[1].ID)
+		assert.Equal(t, second, revisions[1].Outputs)
+
+		layout, err := store.Layout(hash)
+		assert.NoError(t, err)
+		assert.Equal(t, second, layout)
+	})
+
+	t.Run("should only keep the most recent revisions", func(t *testing.T) {
+		store := NewStore(memory.NewBackend())
+		hash := "0123456789abcdef0123456789abcdef"
+
+		for i := 0; i < MaxRevisions+5; i++ {
+			output := testOutput("eDP-1", "laptop", true)
+			output.OffsetX = i
+
+			assert.NoError(t, store.SaveLayout(hash, []Output{output}))
+		}
+
+		revisions, err := store.Revisions(hash)
+		assert.NoError(t, err)
+		assert.Len(t, revisions, MaxRevisions)
+		assert.Equal(t, 6, revisions[0].ID)
+		assert.Equal(t, MaxRevisions+5, revisions[len(revisions)-1].ID)
+	})
+}
+
+func TestStore_Revision(t *testing.T) {
+	store := NewStore(memory.NewBackend())
+	hash := "0123456789abcdef0123456789abcdef"
+
+	assert.NoError(t, store.SaveLayout(hash, []Output{testOutput("eDP-1", "laptop", true)}))
+
+	t.Run("should return the revision with the given ID", func(t *testing.T) {
+		revision, err := store.Revision(hash, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, revision.ID)
+	})
+
+	t.Run("should return an error naming a revision that doesn't exist", func(t *testing.T) {
+		_, err := store.Revision(hash, 3)
+		assert.Equal(t, &RevisionNotFoundError{ID: 3}, err)
+		assert.EqualError(t, err, "xrandr: revision 3 not found")
+	})
+}
+
+func TestStore_SaveWorkspaces(t *testing.T) {
+	t.Run("should save workspaces with a layout, and delete them with it", func(t *testing.T) {
+		store := NewStore(memory.NewBackend())
+		hash := "0123456789abcdef0123456789abcdef"
+
+		workspaces := []i3.Workspace{
+			{Name: "1", Output: "DP-1", IsVisible: true, IsFocused: true},
+			{Name: "2: mail", Output: "eDP-1"},
+		}
+
+		assert.NoError(t, store.SaveLayout(hash, []Output{testOutput("eDP-1", "laptop", true)}))
+		assert.NoError(t, store.SaveWorkspaces(hash, workspaces))
+
+		saved, err := store.Workspaces(hash)
+		assert.NoError(t, err)
+		assert.Equal(t, workspaces, saved)
+
+		assert.NoError(t, store.DeleteLayout(hash))
+
+		saved, err = store.Workspaces(hash)
+		assert.NoError(t, err)
+		assert.Nil(t, saved)
+	})
+}
+
+func TestStore_FindHash(t *testing.T) {
+	store := NewStore(memory.NewBackend())
+	store.SaveLayout("0123456789abcdef0123456789abcdef", nil)
+	store.SaveLayout("0123ffffffffffffffffffffffffffff", nil)
+	store.SetLatestHash("0123ffffffffffffffffffffffffffff")
+
+	hash, err := store.FindHash("01234")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", hash)
+
+	hash, err = store.FindHash("")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123ffffffffffffffffffffffffffff", hash)
+
+	_, err = store.FindHash("0123")
+	assert.Equal(t, ErrAmbiguousHash, err)
+
+	_, err = store.FindHash("fff")
+	assert.Equal(t, ErrLayoutNotFound, err)
+}
+
+func TestStore_SaveProfile(t *testing.T) {
+	store := NewStore(memory.NewBackend())
+
+	assert.NoError(t, store.SaveProfile(Profile{Name: "meeting-room", Hash: "b"}))
+	assert.NoError(t, store.SaveProfile(Profile{Name: "desk", Hash: "a"}))
+	assert.NoError(t, store.SaveProfile(Profile{Name: "desk.alt", Hash: "a"}))
+	assert.Equal(t, ErrInvalidProfileName, store.SaveProfile(Profile{Name: "my/desk"}))
+	assert.Equal(t, ErrInvalidProfileName, store.SaveProfile(Profile{}))
+
+	profiles, err := store.Profiles()
+	assert.NoError(t, err)
+	assert.Len(t, profiles, 3)
+	assert.Equal(t, "desk", profiles[0].Name)
+	assert.Equal(t, "desk.alt", profiles[1].Name)
+	assert.Equal(t, "meeting-room", profiles[2].Name)
+
+	// Profiles aren't layouts, so shouldn't show up as them.
+	hashes, err := store.Hashes()
+	assert.NoError(t, err)
+	assert.Empty(t, hashes)
+
+	assert.NoError(t, store.DeleteProfile("desk"))
+	assert.Equal(t, ErrProfileNotFound, store.DeleteProfile("desk"))
+}
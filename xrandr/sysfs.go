@@ -0,0 +1,343 @@
+package xrandr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultDRMRoot is where the kernel lists DRM connectors in sysfs.
+const DefaultDRMRoot = "/sys/class/drm"
+
+// modesettingTypes maps the kernel's connector types to the names the modesetting driver gives
+// them, where they differ.
+var modesettingTypes = map[string]string{
+	"HDMI-A": "HDMI",
+}
+
+// Connector is a DRM connector, as the kernel sees it.
+type Connector struct {
+	// Card is the card that the connector belongs to, like "card0".
+	Card string
+	// Name is the kernel's name for the connector, like "HDMI-A-1".
+	Name string
+	// ID is the connector's ID, which RandR has as the output's CONNECTOR_ID property, or 0 if the
+	// kernel is too old to say.
+	ID uint32
+	// Status is whether or not something's connected, like "connected" or "disconnected".
+	Status string
+	// IsEnabled is true if the connector is being driven.
+	IsEnabled bool
+	// EDID is the EDID of the connected monitor, if any.
+	EDID []byte
+}
+
+// IsConnected returns true if the kernel thinks a monitor is connected to this connector.
+func (c Connector) IsConnected() bool {
+	return c.Status == "connected"
+}
+
+// Mismatch describes an output that the kernel and X disagree about.
+type Mismatch struct {
+	// Output is RandR's name for the output.
+	Output string
+	// Connector is the kernel's name for the output's connector, like "card0-HDMI-A-1".
+	Connector string
+	// Problem describes what they disagree about.
+	Problem string
+}
+
+// DRMReader reads DRM connectors from sysfs. Right after hotplug, X sometimes reports a connected
+// output without it's EDID, which the kernel already knows, so it's used to fill in the gaps, and
+// to check that the kernel and X agree.
+type DRMReader struct {
+	root string
+}
+
+// NewDRMReader returns a new DRM reader, that reads connectors from the given directory, which is
+// normally DefaultDRMRoot.
+func NewDRMReader(root string) *DRMReader {
+	return &DRMReader{
+		root: root,
+	}
+}
+
+// Connectors returns every DRM connector, sorted by card and name. If there's no DRM directory,
+// there are no connectors.
+func (r *DRMReader) Connectors() ([]Connector, error) {
+	paths, err := filepath.Glob(filepath.Join(r.root, "card*-*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var connectors []Connector
+	for _, path := range paths {
+		connector, err := readConnector(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("xrandr: failed to read connector %s: %v", filepath.Base(path), err)
+		}
+
+		connectors = append(connectors, connector)
+	}
+
+	sort.Slice(connectors, func(i, j int) bool {
+		if connectors[i].Card != connectors[j].Card {
+			return connectors[i].Card < connectors[j].Card
+		}
+
+		return connectors[i].Name < connectors[j].Name
+	})
+
+	return connectors, nil
+}
+
+// readConnector reads the DRM connector in the given directory, which is named like
+// "card0-HDMI-A-1".
+func readConnector(path string) (Connector, error) {
+	var connector Connector
+
+	base := filepath.Base(path)
+	dash := strings.IndexByte(base, '-')
+
+	connector.Card = base[:dash]
+	connector.Name = base[dash+1:]
+
+	status, err := ioutil.ReadFile(filepath.Join(path, "status"))
+	if err != nil {
+		return connector, err
+	}
+
+	connector.Status = strings.TrimSpace(string(status))
+
+	// Some drivers don't have the enabled file, older kernels don't have the connector ID, and the
+	// EDID is only there if something's connected, so they're optional.
+	enabled, err := ioutil.ReadFile(filepath.Join(path, "enabled"))
+	if err == nil {
+		connector.IsEnabled = strings.TrimSpace(string(enabled)) == "enabled"
+	}
+
+	id, err := ioutil.ReadFile(filepath.Join(path, "connector_id"))
+	if err == nil {
+		parsed, err := strconv.ParseUint(strings.TrimSpace(string(id)), 10, 32)
+		if err == nil {
+			connector.ID = uint32(parsed)
+		}
+	}
+
+	edid, err := ioutil.ReadFile(filepath.Join(path, "edid"))
+	if err == nil && len(edid) > 0 {
+		connector.EDID = edid
+	}
+
+	return connector, nil
+}
+
+// connectorOutputNames returns the names that the given DRM connector name may have in RandR. The
+// modesetting driver mostly uses the kernel's names (but "HDMI-A-1" is "HDMI-1"), and the intel
+// driver drops the dash too ("HDMI1").
+func connectorOutputNames(name string) []string {
+	dash := strings.LastIndexByte(name, '-')
+	if dash < 0 {
+		return []string{name}
+	}
+
+	typ, index := name[:dash], name[dash+1:]
+	if mapped, ok := modesettingTypes[typ]; ok {
+		typ = mapped
+	}
+
+	names := []string{name}
+	for _, candidate := range []string{typ + "-" + index, typ + index} {
+		if candidate != names[len(names)-1] && candidate != name {
+			names = append(names, candidate)
+		}
+	}
+
+	return names
+}
+
+// connectorMatch is the DRM connector found for an output, and whether or not it's certainly the
+// right one.
+type connectorMatch struct {
+	Connector
+	isCertain bool
+}
+
+// matchConnectors returns the DRM connector for each of the given outputs that one can be found
+// for, by output name. Outputs are certainly matched by EDID, or by their CONNECTOR_ID property. If
+// that leaves a single connected output, and a single connected connector, they're certainly
+// matched too. Otherwise, outputs are matched by name, as long as only one connector has that name
+// (e.g. with more than one card, there may be a "DP-1" on each), but that's a guess, as drivers
+// don't agree on names, or even on where to count ports from.
+func matchConnectors(outputs []Output, connectors []Connector) map[string]connectorMatch {
+	matched := make(map[string]connectorMatch)
+	used := make(map[int]bool)
+
+	match := func(output Output, i int, isCertain bool) {
+		matched[output.Name] = connectorMatch{Connector: connectors[i], isCertain: isCertain}
+		used[i] = true
+	}
+
+	for _, output := range outputs {
+		edid := output.Properties["EDID"]
+		if len(edid) == 0 {
+			continue
+		}
+
+		for i, connector := range connectors {
+			if !used[i] && bytes.Equal(edid, connector.EDID) {
+				match(output, i, true)
+				break
+			}
+		}
+	}
+
+	for _, output := range outputs {
+		id, ok := outputConnectorID(output)
+		if _, isMatched := matched[output.Name]; isMatched || !ok {
+			continue
+		}
+
+		// IDs are only unique per card.
+		var candidates []int
+		for i, connector := range connectors {
+			if !used[i] && connector.ID == id {
+				candidates = append(candidates, i)
+			}
+		}
+
+		if len(candidates) == 1 {
+			match(output, candidates[0], true)
+		}
+	}
+
+	var unmatchedOutputs, unmatchedConnectors []int
+	for i, output := range outputs {
+		if _, ok := matched[output.Name]; !ok && output.IsConnected {
+			unmatchedOutputs = append(unmatchedOutputs, i)
+		}
+	}
+
+	for i, connector := range connectors {
+		if !used[i] && connector.IsConnected() {
+			unmatchedConnectors = append(unmatchedConnectors, i)
+		}
+	}
+
+	if len(unmatchedOutputs) == 1 && len(unmatchedConnectors) == 1 {
+		output := outputs[unmatchedOutputs[0]]
+		if len(output.Properties["EDID"]) == 0 {
+			match(output, unmatchedConnectors[0], true)
+		}
+	}
+
+	byName := make(map[string][]int)
+	for i, connector := range connectors {
+		if used[i] {
+			continue
+		}
+
+		for _, name := range connectorOutputNames(connector.Name) {
+			byName[name] = append(byName[name], i)
+		}
+	}
+
+	for _, output := range outputs {
+		if _, ok := matched[output.Name]; ok {
+			continue
+		}
+
+		candidates := byName[output.Name]
+		if len(candidates) == 1 && !used[candidates[0]] {
+			match(output, candidates[0], false)
+		}
+	}
+
+	return matched
+}
+
+// outputConnectorID returns the given output's CONNECTOR_ID property, if it has one.
+func outputConnectorID(output Output) (uint32, bool) {
+	data := output.Properties["CONNECTOR_ID"]
+	if len(data) != 4 {
+		return 0, false
+	}
+
+	id := binary.LittleEndian.Uint32(data)
+
+	return id, id != 0
+}
+
+// crossCheck compares the given outputs, as X sees them, with the given DRM connectors, as the
+// kernel sees them. Outputs that X thinks are connected, but has no EDID for, get the kernel's
+// EDID, as long as it's certainly their connector. Putting a monitor's EDID on the wrong output
+// would be worse than leaving it out. Anything else the two disagree about is returned as a
+// mismatch.
+func crossCheck(outputs []Output, connectors []Connector) ([]Output, []Mismatch) {
+	matched := matchConnectors(outputs, connectors)
+
+	var mismatches []Mismatch
+
+	for i, output := range outputs {
+		connector, ok := matched[output.Name]
+		if !ok {
+			if output.IsConnected && len(output.Properties["EDID"]) == 0 && len(connectors) > 0 {
+				mismatches = append(mismatches, Mismatch{
+					Output:  output.Name,
+					Problem: "X has no EDID for it, and it can't be matched to a kernel connector",
+				})
+			}
+
+			continue
+		}
+
+		mismatch := func(format string, args ...interface{}) {
+			mismatches = append(mismatches, Mismatch{
+				Output:    output.Name,
+				Connector: connector.Card + "-" + connector.Name,
+				Problem:   fmt.Sprintf(format, args...),
+			})
+		}
+
+		if output.IsConnected != connector.IsConnected() {
+			state := "disconnected"
+			if output.IsConnected {
+				state = "connected"
+			}
+
+			mismatch("X says it's %s, but the kernel says it's %s", state, connector.Status)
+			continue
+		}
+
+		if !output.IsConnected || len(connector.EDID) == 0 {
+			continue
+		}
+
+		edid := output.Properties["EDID"]
+
+		switch {
+		case len(edid) == 0 && !connector.isCertain:
+			mismatch("X has no EDID for it, and it may not be this connector, so the kernel's isn't used")
+		case len(edid) == 0:
+			if outputs[i].Properties == nil {
+				outputs[i].Properties = make(Properties)
+			}
+
+			outputs[i].Properties["EDID"] = connector.EDID
+		case !bytes.Equal(edid, connector.EDID):
+			mismatch("X and the kernel have different EDIDs")
+		}
+	}
+
+	return outputs, mismatches
+}
@@ -0,0 +1,223 @@
+package xrandr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDRMReader(t *testing.T) {
+	root, err := ioutil.TempDir("", "i3adc-drm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(root)
+
+	laptopEDID := testEDID("BOE", 0x0747, 0, "", "")
+
+	writeConnector(t, root, "card0-eDP-1", "connected", "enabled", laptopEDID)
+	writeConnector(t, root, "card0-HDMI-A-1", "disconnected", "disabled", nil)
+	writeConnector(t, root, "card1-DP-1", "connected", "", nil)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "card0-eDP-1", "connector_id"), []byte("77\n"), 0644))
+
+	// Things in the DRM directory that aren't connectors are ignored.
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "card0"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "renderD128"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "version"), []byte("drm 1.1.0\n"), 0644))
+
+	t.Run("should read every connector", func(t *testing.T) {
+		connectors, err := NewDRMReader(root).Connectors()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []Connector{
+			{Card: "card0", Name: "HDMI-A-1", Status: "disconnected"},
+			{Card: "card0", Name: "eDP-1", ID: 77, Status: "connected", IsEnabled: true, EDID: laptopEDID},
+			{Card: "card1", Name: "DP-1", Status: "connected"},
+		}, connectors)
+	})
+
+	t.Run("should return no connectors if there's no DRM directory", func(t *testing.T) {
+		connectors, err := NewDRMReader(filepath.Join(root, "missing")).Connectors()
+		assert.NoError(t, err)
+		assert.Empty(t, connectors)
+	})
+}
+
+func TestConnectorOutputNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []string
+	}{
+		{name: "eDP-1", expected: []string{"eDP-1", "eDP1"}},
+		{name: "HDMI-A-1", expected: []string{"HDMI-A-1", "HDMI-1", "HDMI1"}},
+		{name: "DVI-D-2", expected: []string{"DVI-D-2", "DVI-D2"}},
+		{name: "Virtual", expected: []string{"Virtual"}},
+	}
+
+	for _, test := range tests {
+		t.Run("should map "+test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, connectorOutputNames(test.name))
+		})
+	}
+}
+
+func TestCrossCheck(t *testing.T) {
+	laptopEDID := testEDID("BOE", 0x0747, 0, "", "")
+	monitorEDID := testEDID("DEL", 0xa0ec, 12345, "DELL U2515H", "9X2VY5A")
+
+	connectors := []Connector{
+		{Card: "card0", Name: "eDP-1", Status: "connected", EDID: laptopEDID},
+		{Card: "card0", Name: "HDMI-A-1", Status: "connected", EDID: monitorEDID},
+		{Card: "card0", Name: "DP-1", Status: "disconnected"},
+	}
+
+	t.Run("should supply an EDID that X is missing", func(t *testing.T) {
+		outputs := []Output{
+			{Name: "eDP1", IsConnected: true, Properties: Properties{"EDID": laptopEDID}},
+			{Name: "HDMI1", IsConnected: true},
+			{Name: "DP1"},
+		}
+
+		checked, mismatches := crossCheck(outputs, connectors)
+
+		assert.Empty(t, mismatches)
+		assert.Equal(t, monitorEDID, checked[1].Properties["EDID"])
+		assert.Nil(t, checked[2].Properties["EDID"])
+	})
+
+	t.Run("should match outputs by EDID, whatever they're called", func(t *testing.T) {
+		outputs := []Output{
+			{Name: "DisplayPort-0", IsConnected: true, Properties: Properties{"EDID": monitorEDID}},
+		}
+
+		matched := matchConnectors(outputs, connectors)
+		assert.Equal(t, "HDMI-A-1", matched["DisplayPort-0"].Name)
+	})
+
+	t.Run("should flag outputs that X and the kernel disagree about", func(t *testing.T) {
+		corruptEDID := append([]byte(nil), laptopEDID...)
+		corruptEDID[20] ^= 0xff
+
+		outputs := []Output{
+			{Name: "eDP-1", IsConnected: true, Properties: Properties{"EDID": corruptEDID}},
+			{Name: "HDMI-1", IsConnected: false},
+			{Name: "DP-1", IsConnected: true},
+		}
+
+		_, mismatches := crossCheck(outputs, connectors)
+
+		if assert.Len(t, mismatches, 3) {
+			assert.Equal(t, "card0-eDP-1", mismatches[0].Connector)
+			assert.Equal(t, "X and the kernel have different EDIDs", mismatches[0].Problem)
+			assert.Equal(t, "card0-HDMI-A-1", mismatches[1].Connector)
+			assert.Equal(t, "X says it's disconnected, but the kernel says it's connected", mismatches[1].Problem)
+			assert.Equal(t, "card0-DP-1", mismatches[2].Connector)
+			assert.Equal(t, "X says it's connected, but the kernel says it's disconnected", mismatches[2].Problem)
+		}
+	})
+
+	t.Run("should not supply an EDID for an output it can only guess the connector of", func(t *testing.T) {
+		otherEDID := testEDID("GSM", 0x5b7f, 54321, "LG ULTRAFINE", "ABC123")
+
+		// amdgpu numbers it's ports from 0, but the kernel numbers them from 1, so X's HDMI-A-1 is
+		// the kernel's HDMI-A-2.
+		connectors := []Connector{
+			{Card: "card0", Name: "HDMI-A-1", Status: "connected", EDID: monitorEDID},
+			{Card: "card0", Name: "HDMI-A-2", Status: "connected", EDID: otherEDID},
+		}
+
+		outputs := []Output{
+			{Name: "HDMI-A-0", IsConnected: true},
+			{Name: "HDMI-A-1", IsConnected: true},
+		}
+
+		checked, mismatches := crossCheck(outputs, connectors)
+
+		assert.Nil(t, checked[0].Properties["EDID"])
+		assert.Nil(t, checked[1].Properties["EDID"])
+
+		if assert.Len(t, mismatches, 2) {
+			assert.Equal(t, "HDMI-A-0", mismatches[0].Output)
+			assert.Equal(t, "", mismatches[0].Connector)
+			assert.Equal(t, "HDMI-A-1", mismatches[1].Output)
+			assert.Equal(t, "card0-HDMI-A-1", mismatches[1].Connector)
+		}
+	})
+
+	t.Run("should supply an EDID by elimination, whatever the output is called", func(t *testing.T) {
+		outputs := []Output{
+			{Name: "eDP", IsConnected: true, Properties: Properties{"EDID": laptopEDID}},
+			{Name: "DisplayPort-0", IsConnected: true},
+			{Name: "DisplayPort-1"},
+		}
+
+		checked, mismatches := crossCheck(outputs, connectors)
+
+		assert.Empty(t, mismatches)
+		assert.Equal(t, monitorEDID, checked[1].Properties["EDID"])
+	})
+
+	t.Run("should supply an EDID for an output with the connector's ID", func(t *testing.T) {
+		connectors := []Connector{
+			{Card: "card0", Name: "HDMI-A-1", ID: 77, Status: "connected", EDID: monitorEDID},
+			{Card: "card0", Name: "HDMI-A-2", ID: 84, Status: "connected", EDID: laptopEDID},
+		}
+
+		outputs := []Output{
+			{Name: "HDMI-A-0", IsConnected: true, Properties: Properties{"CONNECTOR_ID": {77, 0, 0, 0}}},
+			{Name: "HDMI-A-1", IsConnected: true, Properties: Properties{"CONNECTOR_ID": {84, 0, 0, 0}}},
+		}
+
+		checked, mismatches := crossCheck(outputs, connectors)
+
+		assert.Empty(t, mismatches)
+		assert.Equal(t, monitorEDID, checked[0].Properties["EDID"])
+		assert.Equal(t, laptopEDID, checked[1].Properties["EDID"])
+	})
+
+	t.Run("should not match a name shared by connectors on different cards", func(t *testing.T) {
+		connectors := []Connector{
+			{Card: "card0", Name: "DP-1", Status: "connected"},
+			{Card: "card1", Name: "DP-1", Status: "connected"},
+		}
+
+		outputs := []Output{
+			{Name: "DP-1", IsConnected: true},
+			{Name: "DP-1-1", IsConnected: true},
+		}
+
+		assert.Empty(t, matchConnectors(outputs, connectors))
+	})
+}
+
+// writeConnector writes a fake DRM connector directory to the given root. Empty values aren't
+// written at all.
+func writeConnector(t *testing.T, root, name, status, enabled string, edid []byte) {
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"status":  []byte(status + "\n"),
+		"enabled": []byte(enabled + "\n"),
+		"edid":    edid,
+	}
+
+	for file, contents := range files {
+		if len(contents) == 0 || string(contents) == "\n" {
+			continue
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(dir, file), contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -2,13 +2,10 @@ package xrandr
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"os/exec"
+	"time"
 
 	"github.com/seeruk/i3adc/event"
 	"github.com/seeruk/i3adc/logging"
-	"github.com/seeruk/i3adc/state"
 )
 
 // Thread is a process that will wait for events from an event channel, and based on those events,
@@ -16,42 +13,111 @@ import (
 type Thread struct {
 	ctx     context.Context
 	cfn     context.CancelFunc
-	backend state.Backend
-	client  *Client
+	handle  func(evt event.Event) error
 	logger  logging.Logger
 	eventCh <-chan event.Event
+	settle  time.Duration
+
+	newTimer  func() settleTimer
+	onHandled func()
+}
+
+// settleTimer is the part of a *time.Timer that the thread uses to wait for events to settle, so
+// that it can be replaced in tests.
+type settleTimer interface {
+	Chan() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// realTimer is a settleTimer backed by a *time.Timer.
+type realTimer struct {
+	*time.Timer
+}
+
+// Chan returns the channel that the timer fires on.
+func (t realTimer) Chan() <-chan time.Time {
+	return t.C
+}
+
+// newStoppedTimer returns a new settleTimer that isn't running.
+func newStoppedTimer() settleTimer {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	return realTimer{timer}
 }
 
-// NewThread returns a new output thread instance.
-func NewThread(backend state.Backend, client *Client, logger logging.Logger, eventCh <-chan event.Event) *Thread {
+// NewThread returns a new output thread instance. Events are only passed on to the given manager
+// once no more have arrived for the given settle duration, so that bursts of events (e.g. from
+// plugging in a dock) are handled once, after the outputs have settled down.
+func NewThread(manager *Manager, logger logging.Logger, eventCh <-chan event.Event, settle time.Duration) *Thread {
 	logger = logger.With("module", "xrandr/thread")
 
 	return &Thread{
-		backend: backend,
-		client:  client,
-		eventCh: eventCh,
-		logger:  logger,
+		handle:   manager.HandleEvent,
+		eventCh:  eventCh,
+		logger:   logger,
+		settle:   settle,
+		newTimer: newStoppedTimer,
 	}
 }
 
 // Start begins waiting for events in the event channel. When an event occurs, this thread will
-// trigger behaviour to update the display configuration, if necessary.
+// trigger behaviour to update the display configuration, if necessary. The display configuration is
+// also checked once at startup, whether or not any event sources are enabled.
 func (t *Thread) Start() error {
 	t.logger.Info("thread started")
 	t.ctx, t.cfn = context.WithCancel(context.Background())
 
+	t.handleEvent(event.New(event.SourceDaemon, event.ReasonStartup))
+
+	// The timer only runs while an event is pending, and is reset by every event that arrives
+	// before it fires, so only the last event of a burst is handled.
+	timer := t.newTimer()
+	defer timer.Stop()
+
+	var pending *event.Event
+
 	for {
 		select {
 		case <-t.ctx.Done():
 			t.logger.Info("thread stopped")
 			return t.ctx.Err()
-		case evt := <-t.eventCh:
-			err := t.onEvent(evt)
-			if err != nil {
-				t.logger.Errorw("error handling event",
-					"error", err.Error(),
-				)
+		case evt, ok := <-t.eventCh:
+			if !ok {
+				// There'll be no more events, but the thread keeps running until it's stopped.
+				t.eventCh = nil
+				continue
+			}
+
+			if pending != nil {
+				t.logger.Debugw("events coalesced", "older_id", pending.ID, "newer_id", evt.ID)
+				evt = coalesceEvents(*pending, evt)
+			}
+
+			pending = &evt
+
+			if t.settle <= 0 {
+				break
 			}
+
+			if !timer.Stop() {
+				// Drain the channel if the timer fired, but we haven't received from it yet.
+				select {
+				case <-timer.Chan():
+				default:
+				}
+			}
+
+			timer.Reset(t.settle)
+			continue
+		case <-timer.Chan():
+		}
+
+		if pending != nil {
+			t.handleEvent(*pending)
+			pending = nil
 		}
 	}
 }
@@ -67,168 +133,50 @@ func (t *Thread) Stop() error {
 	return nil
 }
 
-func (t *Thread) onEvent(evt event.Event) error {
-	t.logger.Debug("event occurred")
-
-	currentLayout, err := t.client.GetOutputs()
-	if err != nil {
-		return err
-	}
+// OnHandled sets a function to call after each event has been handled, whether or not handling it
+// succeeded. It must be set before the thread is started.
+func (t *Thread) OnHandled(fn func()) {
+	t.onHandled = fn
+}
 
-	hash, err := calculateHashForOutputs(currentLayout)
+// handleEvent passes the given event to the manager, logging any error.
+func (t *Thread) handleEvent(evt event.Event) {
+	err := t.handle(evt)
 	if err != nil {
-		return err
+		t.logger.Errorw("error handling event",
+			"event_id", evt.ID,
+			"source", evt.Source,
+			"reason", evt.Reason,
+			"error", err.Error(),
+		)
 	}
 
-	t.logger.Debugw("calculated hash", "hash", hash)
-
-	latestHashBS, err := t.backend.Read(state.KeyLatestLayout)
-	if err != nil {
-		return err
+	if t.onHandled != nil {
+		t.onHandled()
 	}
+}
 
-	latestHash := string(latestHashBS)
-
-	t.logger.Debugw("latest hash", "hash", latestHash)
+// IsImportantEvent returns true if the given input event shouldn't be dropped to make room for
+// others, because it asks for the saved layout to be applied, or for an action. They're the same
+// events that are kept when a burst of events is coalesced.
+func IsImportantEvent(evt event.Event) bool {
+	return isReapply(evt.Reason) || evt.Action != nil
+}
 
-	savedLayoutBS, err := t.backend.Read(hash)
-	if err != nil {
-		return err
+// coalesceEvents returns the event that should be handled in place of the given older event, and
+// the newer event that superseded it. Usually that's the newer event, but an older event that asks
+// for something more has to be kept.
+func coalesceEvents(older, newer event.Event) event.Event {
+	// Some events mean the saved layout should be applied, rather than the current outputs saved.
+	// If a burst started with one of those, and it was lost, a transitional state could be saved.
+	if isReapply(older.Reason) && !isReapply(newer.Reason) {
+		return older
 	}
 
-	switch {
-	case savedLayoutBS == nil:
-		// If we haven't got a layout stored for this hash, we should activate the preferred mode
-		// for all connected currentLayout. The user can then set their configuration themselves to update
-		// the saved configuration. This is a new layout.
-		t.logger.Infow("creating a new configuration", "hash", hash)
-
-		var lastXPos int
-
-		// For all connected outputs, activate the preferred mode, and do a little bit of auto
-		// config to do with things like rotation, reflection, and position. Fairly standard.
-		for _, output := range currentLayout {
-			var args []string
-
-			if !output.IsConnected {
-				// We have to turn off ones that shouldn't be there!
-				args = []string{
-					"--output", output.Name,
-					"--off",
-				}
-			} else {
-				var preferredMode *Mode
-				for _, mode := range output.Modes {
-					m := mode
-					if mode.IsPreferred {
-						preferredMode = &m
-					}
-				}
-
-				// Otherwise, set some sensible default settings.
-				args = []string{
-					"--output", output.Name,
-					"--pos", fmt.Sprintf("%dx0", lastXPos),
-					"--rotate", "normal",
-					"--reflect", "normal",
-				}
-
-				if lastXPos == 0 {
-					args = append(args, "--primary")
-				}
-
-				if preferredMode == nil {
-					args = append(args, "--auto")
-				} else {
-					args = append(args, "--mode", preferredMode.Name)
-					lastXPos += int(preferredMode.Width)
-				}
-			}
-
-			t.logger.Debugw("running command", "command", "xrandr", "args", args)
-
-			cmd := exec.Command("xrandr", args...)
-
-			// Attempt to run xrandr command...
-			err = cmd.Run()
-			if err != nil {
-				return err
-			}
-		}
-
-		// Re-fetch layout, so our changes are applied to our in-memory representation.
-		currentLayout, err := t.client.GetOutputs()
-		if err != nil {
-			return err
-		}
-
-		currentLayoutBS, err := json.Marshal(currentLayout)
-		if err != nil {
-			return err
-		}
-
-		t.backend.Write(state.KeyLatestLayout, []byte(hash))
-		t.backend.Write(hash, currentLayoutBS)
-	case !evt.IsStartup && hash == latestHash:
-		// If the hash is the same, we want to update the existing layout at that hash. Either this
-		// output configuration has been used before, or the user has just updated it. Technically,
-		// all we need to do is that update here...
-		t.logger.Infow("updating an existing configuration", "hash", hash)
-
-		currentLayoutBS, err := json.Marshal(currentLayout)
-		if err != nil {
-			return err
-		}
-
-		t.backend.Write(state.KeyLatestLayout, []byte(hash))
-		t.backend.Write(hash, currentLayoutBS)
-	default:
-		// Otherwise, we aren't updating layout, or creating a new one, we're simply switching to
-		// another layout. In other words, we should just apply the `savedLayoutBS` configuration.
-		t.logger.Infow("switching to existing configuration", "hash", hash, "previous_hash", latestHash)
-
-		var savedLayout []Output
-
-		err := json.Unmarshal(savedLayoutBS, &savedLayout)
-		if err != nil {
-			return err
-		}
-
-		for _, output := range savedLayout {
-			var args []string
-
-			if !output.IsConnected || !output.IsEnabled {
-				args = []string{
-					"--output", output.Name,
-					"--off",
-				}
-			} else {
-				args = []string{
-					"--output", output.Name,
-					"--mode", fmt.Sprintf("%dx%d", output.Width, output.Height),
-					"--pos", fmt.Sprintf("%dx%d", output.OffsetX, output.OffsetY),
-					"--rotate", output.Rotation.String(),
-					"--reflect", output.Reflection.String(),
-				}
-
-				if output.IsPrimary {
-					args = append(args, "--primary")
-				}
-			}
-
-			t.logger.Debugw("running command", "command", "xrandr", "args", args)
-
-			cmd := exec.Command("xrandr", args...)
-
-			// Attempt to run xrandr command...
-			err = cmd.Run()
-			if err != nil {
-				return err
-			}
-		}
-
-		t.backend.Write(state.KeyLatestLayout, []byte(hash))
+	// An action that was asked for shouldn't be lost just because the outputs changed too.
+	if older.Action != nil && newer.Action == nil {
+		return older
 	}
 
-	return nil
+	return newer
 }
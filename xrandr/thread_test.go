@@ -0,0 +1,218 @@
+package xrandr
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seeruk/i3adc/daemon"
+	"github.com/seeruk/i3adc/event"
+	"github.com/seeruk/i3adc/logging/noop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThread(t *testing.T) {
+	// newTestThread returns a running thread, a channel that receives the events it handles, and a
+	// timer that decides when events have settled.
+	newTestThread := func() (chan<- event.Event, <-chan event.Event, *fakeTimer, context.CancelFunc) {
+		eventCh := make(chan event.Event)
+		handledCh := make(chan event.Event, 10)
+		timer := newFakeTimer()
+
+		thread := &Thread{
+			handle: func(evt event.Event) error {
+				handledCh <- evt
+				return nil
+			},
+			logger:   noop.NewLogger(),
+			eventCh:  eventCh,
+			settle:   time.Second,
+			newTimer: func() settleTimer { return timer },
+		}
+
+		ctx, cfn := context.WithCancel(context.Background())
+		daemon.NewBackgroundThread(ctx, thread)
+
+		return eventCh, handledCh, timer, cfn
+	}
+
+	// send sends the given event to the thread, and waits for it to start waiting for events to
+	// settle again.
+	send := func(t *testing.T, eventCh chan<- event.Event, timer *fakeTimer, evt event.Event) {
+		eventCh <- evt
+
+		select {
+		case <-timer.resets:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the timer to be reset")
+		}
+	}
+
+	t.Run("should handle a startup event straight away", func(t *testing.T) {
+		_, handledCh, _, cfn := newTestThread()
+		defer cfn()
+
+		assert.Equal(t, event.ReasonStartup, receiveEvent(t, handledCh).Reason)
+	})
+
+	t.Run("should handle a burst of events once, after they settle", func(t *testing.T) {
+		eventCh, handledCh, timer, cfn := newTestThread()
+		defer cfn()
+
+		receiveEvent(t, handledCh)
+
+		var last event.Event
+		for i := 0; i < 5; i++ {
+			last = event.New(event.SourceI3, event.ReasonHotplug)
+			send(t, eventCh, timer, last)
+		}
+
+		assert.Len(t, handledCh, 0)
+
+		timer.fire()
+
+		assert.Equal(t, last, receiveEvent(t, handledCh))
+	})
+
+	t.Run("should handle events that are further apart separately", func(t *testing.T) {
+		eventCh, handledCh, timer, cfn := newTestThread()
+		defer cfn()
+
+		receiveEvent(t, handledCh)
+
+		for i := 0; i < 2; i++ {
+			evt := event.New(event.SourceI3, event.ReasonHotplug)
+			send(t, eventCh, timer, evt)
+			timer.fire()
+
+			assert.Equal(t, evt, receiveEvent(t, handledCh))
+		}
+	})
+}
+
+func TestCoalesceEvents(t *testing.T) {
+	hotplug := event.New(event.SourceI3, event.ReasonHotplug)
+	startup := event.New(event.SourceDaemon, event.ReasonStartup)
+	request := event.New(event.SourceSignal, event.ReasonUserRequest)
+	reconnect := event.New(event.SourceI3, event.ReasonReconnect)
+	apply := event.New(event.SourceControl, event.ReasonUserRequest).WithAction(event.Action{
+		Type: event.ActionApply,
+		Ref:  "work",
+	})
+
+	tests := []struct {
+		name     string
+		older    event.Event
+		newer    event.Event
+		expected event.Event
+	}{
+		{name: "should keep the newer event", older: hotplug, newer: request, expected: request},
+		{name: "should keep startup events", older: startup, newer: hotplug, expected: startup},
+		{name: "should keep user requests", older: request, newer: hotplug, expected: request},
+		{name: "should keep reconnects", older: reconnect, newer: hotplug, expected: reconnect},
+		{name: "should keep actions", older: apply, newer: request, expected: apply},
+		{name: "should keep newer actions", older: request, newer: apply, expected: apply},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, coalesceEvents(test.older, test.newer))
+		})
+	}
+}
+
+// receiveEvent returns the next event handled by a test thread.
+func TestIsImportantEvent(t *testing.T) {
+	t.Run("should keep important events when a subscription is flooded", func(t *testing.T) {
+		bus := event.NewBus(noop.NewLogger())
+		sub := bus.Subscribe("xrandr", 8, event.DropOldest, event.TypeInput).Keep(IsImportantEvent)
+
+		resume := event.New(event.SourceLogind, event.ReasonResume)
+		apply := event.New(event.SourceControl, event.ReasonUserRequest).
+			WithAction(event.Action{Type: event.ActionApply})
+
+		bus.Publish(resume)
+		for i := 0; i < 100; i++ {
+			bus.Publish(event.New(event.SourceUdev, event.ReasonHotplug))
+		}
+
+		bus.Publish(apply)
+		for i := 0; i < 100; i++ {
+			bus.Publish(event.New(event.SourceUdev, event.ReasonHotplug))
+		}
+
+		var received []event.Event
+		for len(received) < 8 {
+			received = append(received, <-sub.Events())
+		}
+
+		assert.Equal(t, resume, received[0])
+		assert.Equal(t, apply, received[1])
+		assert.Equal(t, event.ReasonHotplug, received[7].Reason)
+		assert.Equal(t, 194, sub.Dropped())
+	})
+}
+
+func receiveEvent(t *testing.T, handledCh <-chan event.Event) event.Event {
+	select {
+	case evt := <-handledCh:
+		return evt
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event to be handled")
+		return event.Event{}
+	}
+}
+
+// fakeTimer is a settleTimer that only fires when it's told to, and reports each time it's reset.
+type fakeTimer struct {
+	mu     sync.Mutex
+	active bool
+	ch     chan time.Time
+	resets chan struct{}
+}
+
+// newFakeTimer returns a new fakeTimer that isn't running.
+func newFakeTimer() *fakeTimer {
+	return &fakeTimer{
+		ch:     make(chan time.Time, 1),
+		resets: make(chan struct{}, 10),
+	}
+}
+
+// Chan returns the channel that the timer fires on.
+func (t *fakeTimer) Chan() <-chan time.Time {
+	return t.ch
+}
+
+// Reset starts the timer, whatever the given duration.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.mu.Lock()
+	wasActive := t.active
+	t.active = true
+	t.mu.Unlock()
+
+	t.resets <- struct{}{}
+
+	return wasActive
+}
+
+// Stop stops the timer.
+func (t *fakeTimer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wasActive := t.active
+	t.active = false
+
+	return wasActive
+}
+
+// fire fires the timer, as if it's duration had passed.
+func (t *fakeTimer) fire() {
+	t.mu.Lock()
+	t.active = false
+	t.mu.Unlock()
+
+	t.ch <- time.Now()
+}
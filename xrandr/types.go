@@ -58,12 +58,14 @@ type Output struct {
 	IsEnabled   bool       `json:"is_enabled"`
 	IsPrimary   bool       `json:"is_primary"`
 	ModeName    string     `json:"mode_name"`
+	Rate        float64    `json:"rate,omitempty"`
 	Width       uint       `json:"width_px"`
 	Height      uint       `json:"height_px"`
 	OffsetX     int        `json:"offset_x"`
 	OffsetY     int        `json:"offset_y"`
 	Rotation    Rotation   `json:"rotation"`
 	Reflection  Reflection `json:"reflection"`
+	Scale       float64    `json:"scale,omitempty"`
 	Properties  Properties `json:"properties,omitempty"`
 	Modes       []Mode     `json:"modes"`
 }
@@ -73,9 +75,10 @@ type Properties map[string][]byte
 
 // Mode represents an randr mode, only including the information we need.
 type Mode struct {
-	ID          uint   `json:"id"`
-	Name        string `json:"name"`
-	Width       uint   `json:"width"`
-	Height      uint   `json:"height"`
-	IsPreferred bool   `json:"is_preferred"`
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	Width       uint    `json:"width"`
+	Height      uint    `json:"height"`
+	Rate        float64 `json:"rate"`
+	IsPreferred bool    `json:"is_preferred"`
 }